@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// Set the usage of a command, then parse its flags and check it was given count arguments
+func parseCommand(args []string, usage string, count int) error {
+	flag.Usage = func() {
+		fmt.Printf("Usage: %s %s\n", os.Args[0], usage)
+		flag.PrintDefaults()
+	}
+	flag.CommandLine.Parse(args)
+	return checkArgs(count)
+}
+
+func checkArgs(count int) error {
+	if flag.NArg() != count {
+		flag.Usage()
+		return errors.New("")
+	}
+	return nil
+}
+
+// Stop cleanly on Ctrl-C or when the NAS shuts down
+func commandContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// Print the titles of the articles on the wiki, one per line
+func runList(args []string) error {
+	var flagNamespaces = flag.String("namespaces", "", "comma separated namespace IDs or names to list, or \"all\" (default: all content namespaces)")
+	var flagPrefix = flag.String("prefix", "", "only list titles starting with this, including the namespace (such as Recipe:C)")
+	connection := addConnectionFlags()
+	if err := parseCommand(args, "list [OPTIONS] url", 1); err != nil {
+		return err
+	}
+	opened, err := connection.open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer opened.close()
+	wiki := opened.wiki
+	ctx, stop := commandContext()
+	defer stop()
+	titles, _, _, err := listArticles(ctx, wiki, *flagNamespaces)
+	if err != nil {
+		return err
+	}
+	for _, title := range titles {
+		if strings.HasPrefix(title.Title, *flagPrefix) {
+			fmt.Println(title.Title)
+		}
+	}
+	return nil
+}
+
+// Print the latest content of an article, exactly as it is on the wiki
+func runGet(args []string) error {
+	var flagRedirects = flag.Bool("redirects", false, "print the article a redirect points to, instead of the redirect")
+	connection := addConnectionFlags()
+	if err := parseCommand(args, "get [OPTIONS] url title", 2); err != nil {
+		return err
+	}
+	opened, err := connection.open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer opened.close()
+	wiki := opened.wiki
+	ctx, stop := commandContext()
+	defer stop()
+	title := flag.Arg(1)
+	pages, err := wiki.GetPagesContext(ctx, []string{title}, *flagRedirects)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(pages) != 1 || pages[0].Missing:
+		return fmt.Errorf("Article not found: %s", title)
+	case pages[0].Invalid:
+		return fmt.Errorf("Invalid title %s: %s", title, pages[0].InvalidReason)
+	}
+	fmt.Print(pages[0].Content)
+	return nil
+}
+
+// Print which articles were created, changed or deleted on the wiki since they were exported
+func runStatus(args []string) error {
+	var flagNamespaces = flag.String("namespaces", "", "comma separated namespace IDs or names to compare, or \"all\" (default: all content namespaces)")
+	var flagConcurrency = flag.Int("concurrency", 4, "how many batches of articles to compare at once")
+	connection := addConnectionFlags()
+	if err := parseCommand(args, "status [OPTIONS] url exportDir", 2); err != nil {
+		return err
+	}
+	opened, err := connection.open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer opened.close()
+	wiki := opened.wiki
+	options := exportOptions{Site: opened.id, Namespaces: *flagNamespaces, Concurrency: *flagConcurrency}
+	if _, isDump := wiki.(*dumpSource); isDump {
+		options.Concurrency = 1
+		options.BatchSize = dumpBatchSize
+	}
+	ctx, stop := commandContext()
+	defer stop()
+	status, err := compareExport(ctx, wiki, flag.Arg(1), options, localFileSystem{})
+	if err != nil {
+		return err
+	}
+	fmt.Println(status)
+	return nil
+}
+
+// Print how an exported article differs from the wiki, as a unified diff
+func runDiff(args []string) error {
+	connection := addConnectionFlags()
+	if err := parseCommand(args, "diff [OPTIONS] url exportDir title", 3); err != nil {
+		return err
+	}
+	opened, err := connection.open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer opened.close()
+	wiki := opened.wiki
+	ctx, stop := commandContext()
+	defer stop()
+	diff, err := diffArticle(ctx, wiki, flag.Arg(1), flag.Arg(2), localFileSystem{})
+	if err != nil {
+		return err
+	}
+	fmt.Print(diff)
+	return nil
+}
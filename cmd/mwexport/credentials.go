@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	usernameEnv = "MWEXPORT_USERNAME"
+	passwordEnv = "MWEXPORT_PASSWORD"
+)
+
+type credentials struct {
+	Username string
+	Password string
+}
+
+// Where credentials can come from. Split out so tests can stub the environment and terminal
+type credentialSources struct {
+	Getenv     func(key string) string
+	File       string                                        // netrc-style file, skipped if empty or missing
+	IsTerminal func() bool                                   // Whether the user can be prompted
+	Prompt     func(label string, echo bool) (string, error) // Ask the user for a value
+}
+
+func defaultCredentialsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mwexport-credentials")
+}
+
+func localCredentialSources(file string) credentialSources {
+	return credentialSources{
+		Getenv: os.Getenv,
+		File:   file,
+		IsTerminal: func() bool {
+			return term.IsTerminal(int(os.Stdin.Fd()))
+		},
+		Prompt: promptTerminal,
+	}
+}
+
+// Find the credentials for a wiki host. The username comes from the username argument or the environment, and the
+// password from the environment, then the credentials file, then a prompt if attached to a terminal
+func findCredentials(host, username string, sources credentialSources) (credentials, error) {
+	found := credentials{Username: username}
+	if found.Username == "" {
+		found.Username = sources.Getenv(usernameEnv)
+	}
+	found.Password = sources.Getenv(passwordEnv)
+	if found.Username != "" && found.Password != "" {
+		return found, nil
+	}
+	if sources.File != "" {
+		fromFile, ok, err := readCredentialsFile(sources.File, host, found.Username)
+		if err != nil {
+			return credentials{}, err
+		}
+		if ok {
+			return fromFile, nil
+		}
+	}
+	if !sources.IsTerminal() {
+		return credentials{}, fmt.Errorf("No credentials found for %s. Set %s and %s, add them to %s, or use -anonymous", host, usernameEnv, passwordEnv, sources.File)
+	}
+	var err error
+	if found.Username == "" {
+		found.Username, err = sources.Prompt(fmt.Sprintf("Username for %s: ", host), true)
+		if err != nil {
+			return credentials{}, err
+		}
+	}
+	found.Password, err = sources.Prompt(fmt.Sprintf("Password for %s@%s: ", found.Username, host), false)
+	if err != nil {
+		return credentials{}, err
+	}
+	if found.Username == "" || found.Password == "" {
+		return credentials{}, errors.New("Username and password are both required, or use -anonymous")
+	}
+	return found, nil
+}
+
+// Look up a host in a netrc-style credentials file, refusing to use it if other users can read it
+func readCredentialsFile(path, host, username string) (credentials, bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return credentials{}, false, nil
+	}
+	if err != nil {
+		return credentials{}, false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return credentials{}, false, err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return credentials{}, false, fmt.Errorf("Credentials file %s can be read by other users. Run: chmod 600 %s", path, path)
+	}
+	return parseCredentials(file, host, username)
+}
+
+// Parse the machine, login and password tokens of a netrc-style file, skipping entries for other hosts and users
+func parseCredentials(r io.Reader, host, username string) (credentials, bool, error) {
+	type token struct {
+		text string
+		line int
+	}
+	var tokens []token
+	lines := bufio.NewScanner(r)
+	for line := 1; lines.Scan(); line++ {
+		for _, field := range strings.Fields(lines.Text()) {
+			tokens = append(tokens, token{field, line})
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return credentials{}, false, err
+	}
+	var entries []credentials
+	var matching []bool
+	for i := 0; i < len(tokens); i++ {
+		switch current := tokens[i]; current.text {
+		case "machine":
+			if i++; i == len(tokens) {
+				return credentials{}, false, errors.New("Credentials file ends after machine")
+			}
+			entries = append(entries, credentials{})
+			matching = append(matching, strings.EqualFold(tokens[i].text, host))
+		case "default":
+			entries = append(entries, credentials{})
+			matching = append(matching, true)
+		case "login", "password":
+			if len(entries) == 0 {
+				return credentials{}, false, fmt.Errorf("Credentials file has %s before any machine on line %d", current.text, current.line)
+			}
+			if i++; i == len(tokens) {
+				return credentials{}, false, fmt.Errorf("Credentials file ends after %s", current.text)
+			}
+			if current.text == "login" {
+				entries[len(entries)-1].Username = tokens[i].text
+			} else {
+				entries[len(entries)-1].Password = tokens[i].text
+			}
+		default:
+			return credentials{}, false, fmt.Errorf("Unknown token on line %d of the credentials file. Passwords cannot contain spaces", current.line)
+		}
+	}
+	for i, entry := range entries {
+		if matching[i] && entry.Password != "" && (username == "" || entry.Username == username) {
+			return entry, true, nil
+		}
+	}
+	return credentials{}, false, nil
+}
+
+func promptTerminal(label string, echo bool) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	if !echo {
+		value, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return string(value), err
+	}
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(value), err
+}
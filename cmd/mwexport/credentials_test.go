@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCredentialsFile = `
+machine other.example.org login someone password elsewhere
+machine wiki.example.org
+	login backup@cron
+	password s3cret
+machine wiki.example.org login admin password adminpass
+default login guest password guestpass
+`
+
+func TestParseCredentials(t *testing.T) {
+	assertParseCredentials(t, "wiki.example.org", "", credentials{Username: "backup@cron", Password: "s3cret"})
+	assertParseCredentials(t, "WIKI.example.org", "admin", credentials{Username: "admin", Password: "adminpass"})
+	assertParseCredentials(t, "other.example.org", "", credentials{Username: "someone", Password: "elsewhere"})
+	assertParseCredentials(t, "unknown.example.org", "", credentials{Username: "guest", Password: "guestpass"})
+	for _, content := range []string{"login a password b", "machine", "machine a login", "machine a account b"} {
+		if _, _, err := parseCredentials(strings.NewReader(content), "a", ""); err == nil {
+			t.Errorf("Parsing %v should have failed", content)
+		}
+	}
+	// A password with a space must not end up in the error, or the logs
+	_, _, err := parseCredentials(strings.NewReader("machine a\nlogin b password half secret"), "a", "")
+	if err == nil || strings.Contains(err.Error(), "secret") || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Wrong error: %v", err)
+	}
+	if _, found, _ := parseCredentials(strings.NewReader("machine a login b"), "a", ""); found {
+		t.Errorf("Entries without a password should be skipped")
+	}
+}
+
+func assertParseCredentials(t *testing.T, host, username string, expected credentials) {
+	actual, found, err := parseCredentials(strings.NewReader(testCredentialsFile), host, username)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if !found || actual != expected {
+		t.Errorf("Credentials for %v@%v should be %v: %v", username, host, expected, actual)
+	}
+}
+
+func TestCredentialsFilePermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials")
+	if err = ioutil.WriteFile(path, []byte(testCredentialsFile), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, _, err = readCredentialsFile(path, "wiki.example.org", ""); err == nil {
+		t.Errorf("Should have refused a world readable credentials file")
+	}
+	if err = os.Chmod(path, 0600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found, ok, err := readCredentialsFile(path, "wiki.example.org", "")
+	if err != nil || !ok || found.Password != "s3cret" {
+		t.Errorf("Should have read the credentials file: %v %v %v", found, ok, err)
+	}
+	if _, ok, err = readCredentialsFile(filepath.Join(dir, "missing"), "wiki.example.org", ""); ok || err != nil {
+		t.Errorf("A missing credentials file should be skipped: %v %v", ok, err)
+	}
+}
+
+func testSources(env map[string]string, terminal bool, prompts ...string) credentialSources {
+	return credentialSources{
+		Getenv: func(key string) string {
+			return env[key]
+		},
+		IsTerminal: func() bool {
+			return terminal
+		},
+		Prompt: func(label string, echo bool) (string, error) {
+			if len(prompts) == 0 {
+				return "", errors.New("Unexpected prompt: " + label)
+			}
+			value := prompts[0]
+			prompts = prompts[1:]
+			return value, nil
+		},
+	}
+}
+
+func TestFindCredentials(t *testing.T) {
+	env := map[string]string{usernameEnv: "envuser", passwordEnv: "envpass"}
+	assertFindCredentials(t, "", testSources(env, false), credentials{Username: "envuser", Password: "envpass"})
+	assertFindCredentials(t, "flaguser", testSources(env, false), credentials{Username: "flaguser", Password: "envpass"})
+	assertFindCredentials(t, "", testSources(nil, true, "promptuser", "promptpass"), credentials{Username: "promptuser", Password: "promptpass"})
+	assertFindCredentials(t, "flaguser", testSources(nil, true, "promptpass"), credentials{Username: "flaguser", Password: "promptpass"})
+	if _, err := findCredentials("wiki.example.org", "", testSources(nil, false)); err == nil {
+		t.Errorf("Should have failed without any credentials")
+	}
+	if _, err := findCredentials("wiki.example.org", "", testSources(nil, true, "promptuser", "")); err == nil {
+		t.Errorf("Should have failed on an empty password")
+	}
+}
+
+func assertFindCredentials(t *testing.T, username string, sources credentialSources, expected credentials) {
+	actual, err := findCredentials("wiki.example.org", username, sources)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if actual != expected {
+		t.Errorf("Found %v instead of %v", actual, expected)
+	}
+}
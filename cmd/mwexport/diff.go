@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Lines of unchanged text shown around each change
+const diffContext = 3
+
+// A line that is in both texts (' '), only the first ('-') or only the second ('+')
+type diffLine struct {
+	Op   byte
+	Text string // Including its line ending, if it has one
+}
+
+// Compare two texts line by line, returning a unified diff like diff -u does, or "" if they are the same
+func unifiedDiff(fromName, toName, from, to string) string {
+	lines := diffLines(splitLines(from), splitLines(to))
+	// How many lines of each text come before each line of the diff, for the hunk headers
+	fromLine := make([]int, len(lines)+1)
+	toLine := make([]int, len(lines)+1)
+	for i, line := range lines {
+		fromLine[i+1], toLine[i+1] = fromLine[i], toLine[i]
+		if line.Op != '+' {
+			fromLine[i+1]++
+		}
+		if line.Op != '-' {
+			toLine[i+1]++
+		}
+	}
+	var out strings.Builder
+	for i := 0; i < len(lines); i++ {
+		if lines[i].Op == ' ' {
+			continue
+		}
+		// Changes close enough that their context would overlap go in the same hunk
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i + 1
+		for j := i + 1; j < len(lines) && j-end <= 2*diffContext; j++ {
+			if lines[j].Op != ' ' {
+				end = j + 1
+			}
+		}
+		end += diffContext
+		if end > len(lines) {
+			end = len(lines)
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(fromLine[start], fromLine[end]), hunkRange(toLine[start], toLine[end]))
+		for _, line := range lines[start:end] {
+			out.WriteByte(line.Op)
+			out.WriteString(line.Text)
+			if !strings.HasSuffix(line.Text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end - 1
+	}
+	return out.String()
+}
+
+// The lines from after the first line up to the last, as hunk headers give them. Empty ranges start at the line before
+func hunkRange(first, last int) string {
+	switch last - first {
+	case 0:
+		return fmt.Sprintf("%d,0", first)
+	case 1:
+		return fmt.Sprintf("%d", first+1)
+	}
+	return fmt.Sprintf("%d,%d", first+1, last-first)
+}
+
+// Split text into lines that keep their line endings, so a missing newline at the end is a change like any other
+func splitLines(text string) []string {
+	var lines []string
+	for text != "" {
+		end := strings.IndexByte(text, '\n') + 1
+		if end == 0 {
+			end = len(text)
+		}
+		lines = append(lines, text[:end])
+		text = text[end:]
+	}
+	return lines
+}
+
+// Beyond about this many changed lines, the rest of a diff shows the lines as all replaced instead of searching on
+const maxDiffEdits = 2000
+
+// Find the shortest way to turn a into b, using the linear space algorithm of Eugene Myers
+func diffLines(a, b []string) []diffLine {
+	return appendDiff(nil, a, b)
+}
+
+// Lines both texts start or end with are set aside first, as most edits to an article only touch a small part of it.
+// The rest is split where the shortest diff crosses its middle, and each half diffed the same way
+func appendDiff(lines []diffLine, a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, line := range a[:prefix] {
+		lines = append(lines, diffLine{' ', line})
+	}
+	changedA, changedB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	x, y, found := middleSnake(changedA, changedB)
+	if found && x+y > 0 && x+y < len(changedA)+len(changedB) {
+		lines = appendDiff(lines, changedA[:x], changedB[:y])
+		lines = appendDiff(lines, changedA[x:], changedB[y:])
+	} else {
+		for _, line := range changedA {
+			lines = append(lines, diffLine{'-', line})
+		}
+		for _, line := range changedB {
+			lines = append(lines, diffLine{'+', line})
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', line})
+	}
+	return lines
+}
+
+// Search from both ends of a and b at once for where the shortest diff crosses its middle, within maxDiffEdits
+func middleSnake(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// The furthest x reached on each diagonal k = x - y, from the start (forward) and from the end (backward)
+	forward := make([]int, 2*maxD+2)
+	backward := make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+	delta := n - m
+	// With an odd delta the paths meet while searching forward, otherwise while searching backward
+	odd := delta%2 != 0
+	// Diagonals that ran off the edge of the texts, which are not searched again
+	forwardStart, forwardEnd, backwardStart, backwardEnd := 0, 0, 0, 0
+	for d := 0; d < maxD && 2*d <= maxDiffEdits; d++ {
+		for k := -d + forwardStart; k <= d-forwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				forwardEnd += 2
+			case y > m:
+				forwardStart += 2
+			case odd:
+				if reverseK := offset + delta - k; reverseK >= 0 && reverseK < len(backward) && backward[reverseK] != -1 && x >= n-backward[reverseK] {
+					return x, y, true
+				}
+			}
+		}
+		for k := -d + backwardStart; k <= d-backwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				backwardEnd += 2
+			case y > m:
+				backwardStart += 2
+			case !odd:
+				if forwardK := offset + delta - k; forwardK >= 0 && forwardK < len(forward) && forward[forwardK] != -1 {
+					forwardX := forward[forwardK]
+					if forwardX >= n-x {
+						return forwardX, forwardX - (forwardK - offset), true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Compare an exported article with its latest revision on the wiki, returning a unified diff from the file to the wiki,
+// or "" if they are the same. Whitespace at the end is ignored, as the wiki drops it
+func diffArticle(ctx context.Context, wiki source, exportDir string, title string, fs fileSystem) (string, error) {
+	exported, err := readManifest(fs, exportDir)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case exported == nil:
+		return "", fmt.Errorf("No export found in %s", exportDir)
+	case exported.Head != "":
+		return "", fmt.Errorf("%s is a git export, use git diff instead", exportDir)
+	}
+	page, found := exported.byTitle()[title]
+	if !found {
+		return "", fmt.Errorf("%s is not in the export in %s", title, exportDir)
+	}
+	local, err := readExportedArticle(fs, exportDir, exported, page)
+	if err != nil {
+		return "", err
+	}
+	pages, err := wiki.GetPagesContext(ctx, []string{title}, false)
+	if err != nil {
+		return "", err
+	}
+	if len(pages) != 1 {
+		return "", fmt.Errorf("Article not found: %s", title)
+	}
+	remote := pages[0]
+	switch {
+	case remote.Invalid:
+		return "", fmt.Errorf("Invalid title %s: %s", remote.Requested, remote.InvalidReason)
+	case remote.Missing:
+		return unifiedDiff(page.Filename, title+" (not on the wiki)", local, ""), nil
+	case sameContent(remote.Content, local):
+		return "", nil
+	}
+	return unifiedDiff(page.Filename, fmt.Sprintf("%s (revision %d)", remote.Title, remote.RevisionID), local, remote.Content), nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\nthirteen\nfourteen"
+	to := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\nthirteen\nfourteen\n"
+	expected := `--- local
++++ wiki
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -11,4 +11,4 @@
+ eleven
+ twelve
+ thirteen
+-fourteen
+\ No newline at end of file
++fourteen
+`
+	if actual := unifiedDiff("local", "wiki", from, to); actual != expected {
+		t.Errorf("Wrong diff:\n%s", actual)
+	}
+}
+
+func TestUnifiedDiffMergesCloseChanges(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\n"
+	to := "A\nb\nc\nd\ne\nf\ng\nH\ni\n"
+	expected := "--- a\n+++ b\n@@ -1,9 +1,9 @@\n-a\n+A\n b\n c\n d\n e\n f\n g\n-h\n+H\n i\n"
+	if actual := unifiedDiff("a", "b", from, to); actual != expected {
+		t.Errorf("Wrong diff:\n%s", actual)
+	}
+}
+
+func TestUnifiedDiffOfNothing(t *testing.T) {
+	if actual := unifiedDiff("a", "b", "", "Hello\n"); actual != "--- a\n+++ b\n@@ -0,0 +1 @@\n+Hello\n" {
+		t.Errorf("Wrong diff:\n%s", actual)
+	}
+	if actual := unifiedDiff("a", "b", "Same\n", "Same\n"); actual != "" {
+		t.Errorf("Wrong diff:\n%s", actual)
+	}
+}
+
+func TestDiffLinesIsMinimal(t *testing.T) {
+	lines := diffLines(splitLines("a\nb\nc\na\nb\nb\na\n"), splitLines("c\nb\na\nb\na\nc\n"))
+	changes := 0
+	for _, line := range lines {
+		if line.Op != ' ' {
+			changes++
+		}
+	}
+	// The example from Myers' paper, which takes five edits
+	if changes != 5 {
+		t.Errorf("Wrong diff: %+v", lines)
+	}
+}
+
+func TestDiffLinesOfLargeDifferentTexts(t *testing.T) {
+	// Every line differs, as when an article's line endings were changed
+	var from, to []string
+	for i := 0; i < 20000; i++ {
+		from = append(from, fmt.Sprintf("Line %d\n", i))
+		to = append(to, fmt.Sprintf("Line %d\r\n", i))
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	lines := diffLines(from, to)
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 50<<20 {
+		t.Errorf("Allocated %d bytes", allocated)
+	}
+	if len(lines) != 40000 || lines[0] != (diffLine{'-', "Line 0\n"}) || lines[20000] != (diffLine{'+', "Line 0\r\n"}) {
+		t.Errorf("Expected every line to be replaced, got %d lines", len(lines))
+	}
+}
+
+func TestDiffArticle(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir := writeTestExport(t)
+	defer os.RemoveAll(dir)
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Home"}, false).Return([]mediawiki.Page{
+		{Requested: "Home", Title: "Home", RevisionID: 7, Content: "Welcome\nto the wiki"},
+	}, nil)
+	diff, err := diffArticle(context.Background(), mockClient, dir, "Home", localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "--- " + filepath.Join("Main", "Home.txt") + "\n+++ Home (revision 7)\n@@ -1 +1,2 @@\n" +
+		"-Welcome\n\\ No newline at end of file\n+Welcome\n+to the wiki\n\\ No newline at end of file\n"
+	if diff != expected {
+		t.Errorf("Wrong diff:\n%s", diff)
+	}
+}
+
+func TestDiffArticleUnchanged(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir := writeTestExport(t)
+	defer os.RemoveAll(dir)
+
+	// The wiki drops the newline the file ends with
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"About"}, false).Return([]mediawiki.Page{
+		{Requested: "About", Title: "About", Content: "About us"},
+	}, nil)
+	diff, err := diffArticle(context.Background(), mockClient, dir, "About", localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff != "" {
+		t.Errorf("Expected no diff, got:\n%s", diff)
+	}
+}
+
+func TestDiffArticleNotExported(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir := writeTestExport(t)
+	defer os.RemoveAll(dir)
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	if _, err := diffArticle(context.Background(), mockClient, dir, "Contact", localFileSystem{}); err == nil {
+		t.Errorf("Expected an error for an article that was not exported")
+	}
+}
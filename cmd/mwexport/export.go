@@ -1,61 +1,417 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/golang/glog"
+	"github.com/stevearm/mediawiki-export/filename"
 	"github.com/stevearm/mediawiki-export/mediawiki"
 )
 
-func export(client mediawiki.Client, exportDir string, fs fileSystem) error {
-	titles, err := client.ListArticleTitles()
+type exportOptions struct {
+	Namespaces      string           // Which namespaces to export, see selectNamespaces
+	Encoder         filename.Encoder // How titles are turned into filenames
+	CaseInsensitive bool             // Whether filenames that differ only by case clash in the export directory
+	Concurrency     int              // How many batches of articles to download at once. Defaults to one
+	BatchSize       int              // How many articles each worker asks for at once. Defaults to defaultBatchSize
+	Metadata        string           // Where to keep the metadata of each article, see writeArticle. Empty for none
+	Site            string           // Identifies the wiki, so an export is never updated from another one
+	Full            bool             // Download every article, even if the manifest says it hasn't changed
+	MaxManifestAge  time.Duration    // Download everything if the previous export is older than this. Zero for no limit
+	Removed         string           // What to do with the files of deleted and moved articles. Empty to keep them
+	History         string           // How to keep every revision of each article, see writeHistory. Empty for none
+	Files           bool             // Also download uploaded files, see exportFiles
+	Location        mediawiki.Site   // Where the wiki is served from, for the url of each article. Zero for dumps
+}
+
+// Enough titles for a single request from accounts with high api limits. Others get several requests per batch
+const defaultBatchSize = 500
+
+// Export the wiki into exportDir, downloading only the articles that changed since the manifest was saved
+func export(ctx context.Context, wiki source, exportDir string, options exportOptions, fs fileSystem) (exportSummary, error) {
+	var summary exportSummary
+	titles, namespacesByID, namespaceIDs, err := listArticles(ctx, wiki, options.Namespaces)
 	if err != nil {
-		return err
+		return summary, err
+	}
+	err = fs.MkdirAll(exportDir, 0755)
+	if err != nil {
+		return summary, fmt.Errorf("Cannot use %s as export directory: %v", exportDir, err)
+	}
+	filenames, directories, err := articleFilenames(titles, namespacesByID, exportDir, options, fs.Join)
+	if err != nil {
+		return summary, err
+	}
+	for directory := range directories {
+		err = fs.MkdirAll(fs.Join(exportDir, directory), 0755)
+		if err != nil {
+			return summary, err
+		}
+	}
+	previous, err := readManifest(fs, exportDir)
+	if err != nil {
+		return summary, err
+	}
+	if previous != nil && previous.Site != options.Site {
+		// Nothing the previous export recorded can be trusted, and none of its files may be touched
+		glog.Infof("Previous export came from %s, downloading everything", previous.Site)
+		previous = nil
+	}
+	started := now()
+	changed, err := findChangedTitles(ctx, wiki, previous, namespaceIDs, options, started)
+	if err != nil {
+		return summary, err
+	}
+	// Pages of namespaces that are no longer exported are left alone, and carried over to the new manifest
+	var removed, unselected []manifestPage
+	if previous != nil {
+		for _, page := range previous.Pages {
+			if _, selected := namespacesByID[page.Namespace]; !selected {
+				unselected = append(unselected, page)
+			} else if _, listed := filenames[page.Title]; !listed {
+				removed = append(removed, page)
+			}
+		}
+	}
+	err = propagateRemovals(ctx, wiki, exportDir, previous, removed, filenames, options, fs, &summary)
+	if err != nil {
+		return summary, err
+	}
+	known := previous.byTitle()
+	var outdated []mediawiki.Title
+	for _, title := range titles {
+		page, found := known[title.Title]
+		_, isChanged := changed[title.Title]
+		if changed == nil || !found || isChanged || page.Filename != filenames[title.Title] {
+			outdated = append(outdated, title)
+		}
+	}
+	glog.Infof("Downloading %d of %d articles", len(outdated), len(titles))
+	downloaded, err := downloadArticles(ctx, wiki, outdated, exportDir, filenames, options, fs)
+	if err != nil {
+		return summary, err
+	}
+	summary.Downloaded = len(outdated)
+	summary.Unchanged = len(titles) - len(outdated)
+	current := manifest{Site: options.Site, Started: started, Metadata: options.Metadata, History: options.History}
+	if options.Files {
+		if current.Files, err = exportFiles(ctx, wiki, exportDir, previous, options, fs, &summary); err != nil {
+			return summary, err
+		}
+	} else if previous != nil {
+		// Keep track of the files exported before, so they aren't downloaded again if files are asked for next time
+		current.Files = previous.Files
+	}
+	for _, title := range titles {
+		entry := known[title.Title]
+		if page, found := downloaded[title.Title]; found {
+			entry = manifestPage{
+				Title:      title.Title,
+				PageID:     page.PageID,
+				RevisionID: page.RevisionID,
+				SHA1:       page.SHA1,
+			}
+		}
+		entry.Namespace = title.Namespace
+		entry.Filename = filenames[title.Title]
+		current.Pages = append(current.Pages, entry)
+	}
+	current.Pages = append(current.Pages, unselected...)
+	return summary, writeManifest(fs, exportDir, current)
+}
+
+// List the articles of the selected namespaces, see selectNamespaces. Also returns the selected namespaces, both by ID
+// and as a list of IDs
+func listArticles(ctx context.Context, wiki source, selection string) ([]mediawiki.Title, map[int]mediawiki.Namespace, []int, error) {
+	available, err := wiki.ListNamespacesContext(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	namespaces, err := selectNamespaces(available, selection)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	namespaceIDs := make([]int, len(namespaces))
+	namespacesByID := make(map[int]mediawiki.Namespace)
+	for i, namespace := range namespaces {
+		namespaceIDs[i] = namespace.ID
+		namespacesByID[namespace.ID] = namespace
+	}
+	titles, err := wiki.ListTitlesContext(ctx, namespaceIDs)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	if len(titles) == 0 {
-		return fmt.Errorf("Found 0 articles")
+		return nil, nil, nil, fmt.Errorf("Found 0 articles")
+	}
+	return titles, namespacesByID, namespaceIDs, nil
+}
+
+// Work out the file of each article by title, relative to the export directory, along with the directories they go
+// in. The wiki is always queried with the real title, the encoded one is only used for the output path
+func articleFilenames(titles []mediawiki.Title, namespacesByID map[int]mediawiki.Namespace, exportDir string, options exportOptions, join func(dir, name string) string) (map[string]string, map[string]struct{}, error) {
+	filenames := make(map[string]string)
+	uniqueFilenames := make(map[string]string)
+	directories := make(map[string]struct{})
+	for _, title := range titles {
+		namespace, found := namespacesByID[title.Namespace]
+		if !found {
+			return nil, nil, fmt.Errorf("Article %s is in unexpected namespace %d", title.Title, title.Namespace)
+		}
+		directory := options.Encoder.Encode(namespaceDirectory(namespace))
+		name := stripNamespace(namespace, title.Title)
+		outputPath := join(directory, fmt.Sprintf("%s.txt", options.Encoder.Encode(name)))
+		other, found := uniqueFilenames[uniqueKey(outputPath, options.CaseInsensitive)]
+		if found && options.CaseInsensitive && other != title.Title {
+			// Fall back to an encoding that keeps the case of the title visible
+			if caseSafe := options.Encoder.EncodeCaseSafe(name); caseSafe != "" {
+				outputPath = join(directory, fmt.Sprintf("%s.txt", caseSafe))
+				other, found = uniqueFilenames[uniqueKey(outputPath, true)]
+			}
+		}
+		if found {
+			return nil, nil, fmt.Errorf("Found duplicate title: %s and %s would both be saved as %s", other, title.Title, join(exportDir, outputPath))
+		}
+		uniqueFilenames[uniqueKey(outputPath, options.CaseInsensitive)] = title.Title
+		filenames[title.Title] = outputPath
+		directories[directory] = struct{}{}
+	}
+	return filenames, directories, nil
+}
+
+// Find the titles that changed since the previous export, using the wiki's recent changes. Returns nil if everything
+// has to be downloaded
+func findChangedTitles(ctx context.Context, wiki source, previous *manifest, namespaceIDs []int, options exportOptions, started time.Time) (map[string]struct{}, error) {
+	switch {
+	case options.Full:
+		return nil, nil
+	case previous == nil:
+		glog.Info("No manifest from a previous export, downloading everything")
+		return nil, nil
+	case previous.Metadata != options.Metadata:
+		glog.Info("Metadata format changed, downloading everything")
+		return nil, nil
+	case previous.History != options.History:
+		glog.Info("History format changed, downloading everything")
+		return nil, nil
+	case options.MaxManifestAge > 0 && started.Sub(previous.Started) > options.MaxManifestAge:
+		glog.Infof("Previous export from %s is too old, downloading everything", previous.Started)
+		return nil, nil
 	}
-	var scrubber scrubber
-	err = scrubber.Init()
+	changes, err := wiki.RecentChangesContext(ctx, previous.Started.Add(-manifestOverlap), namespaceIDs)
 	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]struct{})
+	for _, change := range changes {
+		changed[change.Title] = struct{}{}
+	}
+	return changed, nil
+}
+
+// Download every article into its file on a pool of workers, and return what was written by title without content
+func downloadArticles(ctx context.Context, wiki source, titles []mediawiki.Title, exportDir string, filenames map[string]string, options exportOptions, fs fileSystem) (map[string]mediawiki.Page, error) {
+	batchSize := options.BatchSize
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+	var batches [][]string
+	for start := 0; start < len(titles); start += batchSize {
+		var batch []string
+		for i := start; i < start+batchSize && i < len(titles); i++ {
+			batch = append(batch, titles[i].Title)
+		}
+		batches = append(batches, batch)
+	}
+	results := make([][]mediawiki.Page, len(batches))
+	err := runWorkers(ctx, options.Concurrency, len(batches), func(index int) error {
+		var err error
+		results[index], err = downloadBatch(ctx, wiki, batches[index], exportDir, filenames, options, fs)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	uniqueTitles := make(map[string]struct{})
-	for _, title := range titles {
-		scrubbedTitle := scrubber.Scrub(title)
-		if _, found := uniqueTitles[scrubbedTitle]; found {
-			return fmt.Errorf("Found duplicate title: %s", scrubbedTitle)
+	downloaded := make(map[string]mediawiki.Page)
+	for _, pages := range results {
+		for _, page := range pages {
+			downloaded[page.Requested] = page
 		}
-		uniqueTitles[scrubbedTitle] = struct{}{}
 	}
-	for title := range uniqueTitles {
-		article, err := client.GetArticle(title)
-		if err != nil {
-			return err
+	return downloaded, nil
+}
+
+// Call work with each index below count on a pool of workers, returning the error of the earliest index that failed
+func runWorkers(ctx context.Context, concurrency int, count int, work func(index int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	errs := make([]error, count)
+	indexes := make(chan int)
+	failed := make(chan struct{})
+	var failOnce sync.Once
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for index := range indexes {
+				errs[index] = work(index)
+				if errs[index] != nil {
+					failOnce.Do(func() { close(failed) })
+				}
+			}
+		}()
+	}
+	// Indexes are handed out in order, so every one before a failed one has been started by the time we stop
+dispatch:
+	for index := 0; index < count; index++ {
+		select {
+		case indexes <- index:
+		case <-failed:
+			break dispatch
+		case <-ctx.Done():
+			break dispatch
 		}
-		filename := fmt.Sprintf("%s.txt", title)
-		articleBytes := []byte(article)
-		err = fs.WriteFile(filename, articleBytes, 0644)
+	}
+	close(indexes)
+	workers.Wait()
+	for _, err := range errs {
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return ctx.Err()
+}
+
+// Download a batch of articles into their files, along with their history if asked to, stopping at the first one that
+// fails. Returns the pages without their content
+func downloadBatch(ctx context.Context, wiki source, titles []string, exportDir string, filenames map[string]string, options exportOptions, fs fileSystem) ([]mediawiki.Page, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pages, err := wiki.GetPagesContext(ctx, titles, false)
+	if err != nil {
+		return nil, err
+	}
+	for i, page := range pages {
+		switch {
+		case page.Invalid:
+			return nil, fmt.Errorf("Invalid title %s: %s", page.Requested, page.InvalidReason)
+		case page.Missing:
+			return nil, fmt.Errorf("Article not found: %s", page.Requested)
+		case page.RevisionID == 0 || page.ContentHidden:
+			// Blank articles are fine, but one whose content can't be read would lose it from the export
+			return nil, fmt.Errorf("Article %s came back without its content", page.Requested)
+		}
+		filename, found := filenames[page.Requested]
+		if !found {
+			return nil, fmt.Errorf("Got unexpected article %s", page.Requested)
+		}
+		url := ""
+		if options.Location.Host != "" {
+			url = options.Location.ArticleUrl(page.Title)
+		}
+		if err = writeArticle(fs, fs.Join(exportDir, filename), page, options.Metadata, url); err != nil {
+			return nil, err
+		}
+		if err = writeHistory(ctx, wiki, fs, exportDir, page.Requested, filename, options.History); err != nil {
+			return nil, err
+		}
+		pages[i].Content = ""
+	}
+	return pages, nil
 }
 
-type scrubber struct {
-	regex *regexp.Regexp
+// The key that two filenames share if they refer to the same file in the export directory
+func uniqueKey(filename string, caseInsensitive bool) string {
+	if caseInsensitive {
+		return strings.ToLower(filename)
+	}
+	return filename
 }
 
-func (s *scrubber) Init() error {
-	regex, err := regexp.Compile("[^A-Za-z0-9_-]")
-	if err != nil {
-		return err
+// Pick the namespaces to export. The selection is either empty for all content namespaces, "all" for every
+// namespace that can hold pages, or a comma separated list of namespace IDs and names
+func selectNamespaces(available []mediawiki.Namespace, selection string) ([]mediawiki.Namespace, error) {
+	var selected []mediawiki.Namespace
+	switch selection {
+	case "":
+		for _, namespace := range available {
+			if namespace.Content && namespace.ID >= 0 {
+				selected = append(selected, namespace)
+			}
+		}
+	case "all":
+		for _, namespace := range available {
+			if namespace.ID >= 0 {
+				selected = append(selected, namespace)
+			}
+		}
+	default:
+		for _, item := range strings.Split(selection, ",") {
+			item = strings.TrimSpace(item)
+			namespace, found := findNamespace(available, item)
+			if !found {
+				return nil, fmt.Errorf("Unknown namespace: %s", item)
+			}
+			if namespace.ID < 0 {
+				return nil, fmt.Errorf("Namespace cannot hold pages: %s", item)
+			}
+			selected = append(selected, namespace)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("No namespaces selected")
+	}
+	// A custom namespace named Main would share the main namespace's directory, and case-insensitive file systems
+	// can't tell names apart by case either
+	for i, namespace := range selected {
+		for _, other := range selected[:i] {
+			if other.ID != namespace.ID && strings.EqualFold(namespaceDirectory(other), namespaceDirectory(namespace)) {
+				return nil, fmt.Errorf("Namespaces %d and %d would both be exported to %s", other.ID, namespace.ID, namespaceDirectory(namespace))
+			}
+		}
+	}
+	return selected, nil
+}
+
+func findNamespace(available []mediawiki.Namespace, item string) (mediawiki.Namespace, bool) {
+	if item == "" {
+		return mediawiki.Namespace{}, false
+	}
+	id, err := strconv.Atoi(item)
+	for _, namespace := range available {
+		if err == nil && namespace.ID == id {
+			return namespace, true
+		}
+		if err != nil && (strings.EqualFold(namespace.Name, item) || strings.EqualFold(namespace.Canonical, item) || strings.EqualFold(namespaceDirectory(namespace), item)) {
+			return namespace, true
+		}
+	}
+	return mediawiki.Namespace{}, false
+}
+
+// Name of the subdirectory that holds the articles of a namespace
+func namespaceDirectory(namespace mediawiki.Namespace) string {
+	if namespace.Canonical != "" {
+		return namespace.Canonical
+	}
+	if namespace.Name != "" {
+		return namespace.Name
 	}
-	s.regex = regex
-	return nil
+	return "Main"
 }
 
-func (s scrubber) Scrub(title string) string {
-	return s.regex.ReplaceAllString(title, "_")
+// Remove the namespace prefix from a title, as the namespace is already represented by the directory
+func stripNamespace(namespace mediawiki.Namespace, title string) string {
+	if namespace.Name == "" {
+		return title
+	}
+	return strings.TrimPrefix(title, namespace.Name+":")
 }
@@ -1,84 +1,448 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/filename"
 	"github.com/stevearm/mediawiki-export/mediawiki"
 )
 
+var testNamespaces = []mediawiki.Namespace{
+	{ID: -1, Name: "Special", Canonical: "Special"},
+	{ID: 0, Content: true},
+	{ID: 1, Name: "Talk", Canonical: "Talk"},
+	{ID: 10, Name: "Template", Canonical: "Template"},
+	{ID: 100, Name: "Recipe", Content: true},
+}
+
 func TestExport(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	mockClient := mediawiki.NewMockClient(mockCtrl)
-	mockClient.EXPECT().ListArticleTitles().Return([]string{
-		"FirstArticle",
-		"SecondArticle",
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "FirstArticle"},
+		{Namespace: 0, Title: "SecondArticle"},
+		{Namespace: 100, Title: "Recipe:Cake"},
 	}, nil)
 
-	mockClient.EXPECT().GetArticle("FirstArticle").Return("This is the first article", nil)
-	mockClient.EXPECT().GetArticle("SecondArticle").Return("This is the second article", nil)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"FirstArticle", "SecondArticle", "Recipe:Cake"}, false).Return([]mediawiki.Page{
+		testPage("FirstArticle", "This is the first article"),
+		testPage("SecondArticle", "This is the second article"),
+		testPage("Recipe:Cake", "Flour and eggs"),
+	}, nil)
 
-	mockFileSystem := NewMockfileSystem(mockCtrl)
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	dirMode := os.FileMode(0755)
 	fileMode := os.FileMode(0644)
-	mockFileSystem.EXPECT().WriteFile("FirstArticle.txt", []byte("This is the first article"), fileMode).Return(nil)
-	mockFileSystem.EXPECT().WriteFile("SecondArticle.txt", []byte("This is the second article"), fileMode).Return(nil)
+	mockFileSystem.EXPECT().MkdirAll("outputFolder", dirMode).Return(nil)
+	mockFileSystem.EXPECT().MkdirAll(filepath.Join("outputFolder", "Main"), dirMode).Return(nil)
+	mockFileSystem.EXPECT().MkdirAll(filepath.Join("outputFolder", "Recipe"), dirMode).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "FirstArticle.txt"), []byte("This is the first article"), fileMode).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "SecondArticle.txt"), []byte("This is the second article"), fileMode).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Recipe", "Cake.txt"), []byte("Flour and eggs"), fileMode).Return(nil)
 
-	err := export(mockClient, "outputFolder", mockFileSystem)
+	_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}}, mockFileSystem)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
 }
 
+func TestExportUsesRealTitles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "Other's House"},
+	}, nil)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Other's House"}, false).Return([]mediawiki.Page{
+		testPage("Other's House", "Someone else lives here"),
+	}, nil)
+
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "Other's_House.txt"), []byte("Someone else lives here"), os.FileMode(0644)).Return(nil)
+
+	_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}}, mockFileSystem)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestExportBlankArticle(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "Blanked"},
+	}, nil)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Blanked"}, false).Return([]mediawiki.Page{
+		testPage("Blanked", ""),
+	}, nil)
+
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "Blanked.txt"), []byte(""), os.FileMode(0644)).Return(nil)
+
+	_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}}, mockFileSystem)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestExportHiddenArticle(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "Hidden"},
+	}, nil)
+	hidden := testPage("Hidden", "")
+	hidden.ContentHidden = true
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Hidden"}, false).Return([]mediawiki.Page{hidden}, nil)
+
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+
+	_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}}, mockFileSystem)
+	if err == nil {
+		t.Errorf("Should have failed on an article without content")
+	}
+}
+
+func TestExportCancelled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(ctx).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(ctx, []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "FirstArticle"},
+		{Namespace: 0, Title: "SecondArticle"},
+	}, nil)
+	mockClient.EXPECT().GetPagesContext(ctx, []string{"FirstArticle"}, false).Do(func(ctx context.Context, titles []string, followRedirects bool) {
+		cancel()
+	}).Return([]mediawiki.Page{testPage("FirstArticle", "This is the first article")}, nil)
+
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "FirstArticle.txt"), []byte("This is the first article"), os.FileMode(0644)).Return(nil)
+
+	_, err := export(ctx, mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}, BatchSize: 1}, mockFileSystem)
+	if err != context.Canceled {
+		t.Errorf("Should have stopped once cancelled: %v", err)
+	}
+}
+
+func TestExportConcurrently(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	titles := []mediawiki.Title{
+		{Namespace: 0, Title: "FirstArticle"},
+		{Namespace: 0, Title: "SecondArticle"},
+		{Namespace: 0, Title: "ThirdArticle"},
+	}
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return(titles, nil)
+	// Every download waits for all the others to start, which only works if they run at the same time
+	var started sync.WaitGroup
+	started.Add(len(titles))
+	for _, title := range titles {
+		mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{title.Title}, false).DoAndReturn(func(ctx context.Context, titles []string, followRedirects bool) ([]mediawiki.Page, error) {
+			started.Done()
+			started.Wait()
+			return []mediawiki.Page{testPage(titles[0], "Text of "+titles[0])}, nil
+		})
+	}
+
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	for _, title := range titles {
+		mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", title.Title+".txt"), []byte("Text of "+title.Title), os.FileMode(0644)).Return(nil)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}, Concurrency: 3, BatchSize: 1}, mockFileSystem)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Articles were not downloaded concurrently")
+	}
+}
+
+func TestExportConcurrentErrorsAreOrdered(t *testing.T) {
+	for attempt := 0; attempt < 20; attempt++ {
+		mockCtrl := gomock.NewController(t)
+
+		mockClient := mediawiki.NewMockClient(mockCtrl)
+		mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+		mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+			{Namespace: 0, Title: "FirstArticle"},
+			{Namespace: 0, Title: "SecondArticle"},
+			{Namespace: 0, Title: "ThirdArticle"},
+			{Namespace: 0, Title: "FourthArticle"},
+			{Namespace: 0, Title: "FifthArticle"},
+		}, nil)
+		// The later failure is quicker, but the earlier one must still be the one reported
+		mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"FirstArticle"}, false).Return([]mediawiki.Page{testPage("FirstArticle", "Text")}, nil).MaxTimes(1)
+		mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"SecondArticle"}, false).DoAndReturn(func(ctx context.Context, titles []string, followRedirects bool) ([]mediawiki.Page, error) {
+			time.Sleep(10 * time.Millisecond)
+			return nil, errors.New("Second failed")
+		}).MaxTimes(1)
+		mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"ThirdArticle"}, false).Return(nil, errors.New("Third failed")).MaxTimes(1)
+		mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"FourthArticle"}, false).Return([]mediawiki.Page{testPage("FourthArticle", "Text")}, nil).MaxTimes(1)
+		mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"FifthArticle"}, false).Return([]mediawiki.Page{testPage("FifthArticle", "Text")}, nil).MaxTimes(1)
+
+		mockFileSystem := newMockFileSystem(mockCtrl)
+		mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+		mockFileSystem.EXPECT().WriteFile(gomock.Any(), []byte("Text"), os.FileMode(0644)).AnyTimes().Return(nil)
+
+		_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}, Concurrency: 3, BatchSize: 1}, mockFileSystem)
+		if err == nil || err.Error() != "Second failed" {
+			t.Fatalf("Should have reported the earliest failure: %v", err)
+		}
+		mockCtrl.Finish()
+	}
+}
+
+func TestExportMissingArticle(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "FirstArticle"},
+		{Namespace: 0, Title: "Deleted meanwhile"},
+	}, nil)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"FirstArticle", "Deleted meanwhile"}, false).Return([]mediawiki.Page{
+		testPage("FirstArticle", "Text"),
+		{Requested: "Deleted meanwhile", Title: "Deleted meanwhile", Missing: true},
+	}, nil)
+
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "FirstArticle.txt"), []byte("Text"), os.FileMode(0644)).Return(nil)
+
+	_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}}, mockFileSystem)
+	if err == nil || err.Error() != "Article not found: Deleted meanwhile" {
+		t.Errorf("Should have failed on a missing article: %v", err)
+	}
+}
+
+func TestExportDirIsFile(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "FirstArticle"},
+	}, nil)
+
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll("outputFolder", os.FileMode(0755)).Return(errors.New("mkdir outputFolder: not a directory"))
+
+	_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}}, mockFileSystem)
+	if err == nil {
+		t.Errorf("Should have refused to export into a file")
+	}
+}
+
+func TestSelectNamespaces(t *testing.T) {
+	assertSelectNamespaces(t, "", []int{0, 100})
+	assertSelectNamespaces(t, "all", []int{0, 1, 10, 100})
+	assertSelectNamespaces(t, "0,10", []int{0, 10})
+	assertSelectNamespaces(t, "Main, template,Recipe", []int{0, 10, 100})
+	for _, selection := range []string{"-1", "Special", "12", "Unknown", "1,"} {
+		if namespaces, err := selectNamespaces(testNamespaces, selection); err == nil {
+			t.Errorf("Selection %v should have failed: %v", selection, namespaces)
+		}
+	}
+}
+
+func TestSelectNamespacesSharingDirectory(t *testing.T) {
+	available := append([]mediawiki.Namespace{{ID: 102, Name: "Main", Content: true}}, testNamespaces...)
+	for _, selection := range []string{"", "all", "0,102"} {
+		if namespaces, err := selectNamespaces(available, selection); err == nil {
+			t.Errorf("Selection %v should have failed: %v", selection, namespaces)
+		}
+	}
+	// Either one alone is fine
+	namespaces, err := selectNamespaces(available, "102,Recipe")
+	if err != nil || len(namespaces) != 2 {
+		t.Errorf("Wrong selection: %v, %v", namespaces, err)
+	}
+}
+
+func assertSelectNamespaces(t *testing.T, selection string, expected []int) {
+	namespaces, err := selectNamespaces(testNamespaces, selection)
+	if err != nil {
+		t.Errorf("Unexpected error selecting %v: %v", selection, err)
+		return
+	}
+	ids := make([]int, len(namespaces))
+	for i, namespace := range namespaces {
+		ids[i] = namespace.ID
+	}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Selection %v chose %v instead of %v", selection, ids, expected)
+	}
+}
+
 func TestDuplicateNames(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	mockClient := mediawiki.NewMockClient(mockCtrl)
-	mockClient.EXPECT().ListArticleTitles().Return([]string{
-		"FirstArticle",
-		"SecondArticle",
-		"Third One",
-		"Third_One",
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "FirstArticle"},
+		{Namespace: 0, Title: "SecondArticle"},
+		{Namespace: 0, Title: "Third One"},
+		{Namespace: 0, Title: "Third_One"},
 	}, nil)
 
-	err := export(mockClient, "outputFolder", nil)
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll("outputFolder", os.FileMode(0755)).Return(nil)
+
+	_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Scrubber{}}, mockFileSystem)
 	if err == nil {
 		t.Errorf("Should have failed on duplicate names")
 	}
 }
 
-func TestNoArticles(t *testing.T) {
+func TestReversibleNames(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	mockClient := mediawiki.NewMockClient(mockCtrl)
-	mockClient.EXPECT().ListArticleTitles().Return([]string{}, nil)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "Third One"},
+		{Namespace: 0, Title: "Third_One"},
+		{Namespace: 0, Title: "Café"},
+	}, nil)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Third One", "Third_One", "Café"}, false).Return([]mediawiki.Page{
+		testPage("Third One", "Spaced"),
+		testPage("Third_One", "Underscored"),
+		testPage("Café", "Coffee"),
+	}, nil)
 
-	err := export(mockClient, "outputFolder", nil)
-	if err == nil {
-		t.Errorf("Should have failed on no names")
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	fileMode := os.FileMode(0644)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "Third_One.txt"), []byte("Spaced"), fileMode).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "Third%5FOne.txt"), []byte("Underscored"), fileMode).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "Café.txt"), []byte("Coffee"), fileMode).Return(nil)
+
+	_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}}, mockFileSystem)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
 	}
 }
 
-func TestScrubTitle(t *testing.T) {
-	var scrubber scrubber
-	err := scrubber.Init()
+func TestCaseInsensitiveNames(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "NASA"},
+		{Namespace: 0, Title: "Nasa"},
+	}, nil)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"NASA", "Nasa"}, false).Return([]mediawiki.Page{
+		testPage("NASA", "Agency"),
+		testPage("Nasa", "Redirect"),
+	}, nil)
+
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	fileMode := os.FileMode(0644)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "NASA.txt"), []byte("Agency"), fileMode).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "%4Easa.txt"), []byte("Redirect"), fileMode).Return(nil)
+
+	options := exportOptions{Encoder: filename.Percent{}, CaseInsensitive: true}
+	_, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
-	assertScrubTitle(t, scrubber, "House", "House")
-	assertScrubTitle(t, scrubber, "OtherHouse", "OtherHouse")
-	assertScrubTitle(t, scrubber, "Other House", "Other_House")
-	assertScrubTitle(t, scrubber, "Other_House", "Other_House")
-	assertScrubTitle(t, scrubber, "Other's House", "Other_s_House")
 }
 
-func assertScrubTitle(t *testing.T, scrubber scrubber, source, expected string) {
-	actual := scrubber.Scrub(source)
-	if actual != expected {
-		t.Errorf("Scrub %v to %v failed: %v", source, expected, actual)
+func TestCaseInsensitiveScrubbedNames(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{
+		{Namespace: 0, Title: "NASA"},
+		{Namespace: 0, Title: "Nasa"},
+	}, nil)
+
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll("outputFolder", os.FileMode(0755)).Return(nil)
+
+	options := exportOptions{Encoder: filename.Scrubber{}, CaseInsensitive: true}
+	_, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
+	if err == nil {
+		t.Errorf("Should have failed on names that differ only by case")
+	}
+}
+
+func TestNoArticles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{}, nil)
+
+	_, err := export(context.Background(), mockClient, "outputFolder", exportOptions{Encoder: filename.Percent{}}, nil)
+	if err == nil {
+		t.Errorf("Should have failed on no names")
 	}
 }
+
+// A page as GetPages returns it when the title needed no normalisation
+func testPage(title string, content string) mediawiki.Page {
+	return mediawiki.Page{Requested: title, Title: title, RevisionID: 1, Content: content}
+}
+
+// A mock filesystem that joins paths the same way the local one does, for exporting into outputFolder where no
+// manifest is found and saving one always works
+func newMockFileSystem(mockCtrl *gomock.Controller) *MockfileSystem {
+	mockFileSystem := NewMockfileSystem(mockCtrl)
+	mockFileSystem.EXPECT().Join(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(dir, name string) string {
+		return filepath.Join(dir, name)
+	})
+	manifestPath := filepath.Join("outputFolder", manifestFilename)
+	mockFileSystem.EXPECT().ReadFile(manifestPath).AnyTimes().Return(nil, os.ErrNotExist)
+	mockFileSystem.EXPECT().WriteFile(manifestPath, gomock.Any(), os.FileMode(0644)).AnyTimes().Return(nil)
+	return mockFileSystem
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+// Where uploaded files go in the export directory, named after the file with the same encoding as titles. Like
+// archiveDirectory, it starts with _ so that no namespace directory can have the same name, whatever the case
+const mediaDirectory = "_files"
+
+// Download the uploaded files that changed since the previous export, and return them all for the manifest
+func exportFiles(ctx context.Context, wiki source, exportDir string, previous *manifest, options exportOptions, fs fileSystem, summary *exportSummary) ([]manifestFile, error) {
+	files, err := wiki.ListFilesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = fs.MkdirAll(fs.Join(exportDir, mediaDirectory), 0755); err != nil {
+		return nil, err
+	}
+	filenames := make(map[string]string)
+	uniqueFilenames := make(map[string]string)
+	for _, file := range files {
+		filename := fs.Join(mediaDirectory, options.Encoder.Encode(file.Name))
+		other, found := uniqueFilenames[uniqueKey(filename, options.CaseInsensitive)]
+		if found && options.CaseInsensitive {
+			if caseSafe := options.Encoder.EncodeCaseSafe(file.Name); caseSafe != "" {
+				filename = fs.Join(mediaDirectory, caseSafe)
+				other, found = uniqueFilenames[uniqueKey(filename, true)]
+			}
+		}
+		if found {
+			return nil, fmt.Errorf("Found duplicate file: %s and %s would both be saved as %s", other, file.Name, fs.Join(exportDir, filename))
+		}
+		uniqueFilenames[uniqueKey(filename, options.CaseInsensitive)] = file.Name
+		filenames[file.Name] = filename
+	}
+
+	known := make(map[string]manifestFile)
+	if previous != nil && !options.Full {
+		for _, file := range previous.Files {
+			known[file.Name] = file
+		}
+	}
+	var outdated []mediawiki.File
+	for _, file := range files {
+		entry, found := known[file.Name]
+		switch {
+		case !found || entry.SHA1 != file.SHA1:
+			outdated = append(outdated, file)
+		case entry.Filename != filenames[file.Name]:
+			// Unchanged files saved under another name, such as in the files directory of older exports, are moved
+			glog.V(1).Infof("Moving %s to %s", entry.Filename, filenames[file.Name])
+			err = fs.Rename(fs.Join(exportDir, entry.Filename), fs.Join(exportDir, filenames[file.Name]))
+			if os.IsNotExist(err) {
+				outdated = append(outdated, file)
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	}
+	glog.Infof("Downloading %d of %d files", len(outdated), len(files))
+	err = runWorkers(ctx, options.Concurrency, len(outdated), func(index int) error {
+		file := outdated[index]
+		return fs.WriteStream(fs.Join(exportDir, filenames[file.Name]), 0644, func(w io.Writer) error {
+			return wiki.DownloadFileContext(ctx, file, w)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	summary.FilesDownloaded = len(outdated)
+	summary.FilesUnchanged = len(files) - len(outdated)
+
+	if previous != nil {
+		for _, file := range previous.Files {
+			if _, listed := filenames[file.Name]; listed {
+				continue
+			}
+			summary.Deleted = append(summary.Deleted, file.Name+" (uploaded file)")
+			if err = removeMediaFile(fs, exportDir, file.Filename, options.Removed); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var exported []manifestFile
+	for _, file := range files {
+		exported = append(exported, manifestFile{Name: file.Name, Size: file.Size, SHA1: file.SHA1, Filename: filenames[file.Name]})
+	}
+	return exported, nil
+}
+
+// Delete or archive a file that is no longer on the wiki, as the policy says, ignoring it if it is already gone
+func removeMediaFile(fs fileSystem, exportDir string, filename string, policy string) error {
+	var err error
+	switch policy {
+	case removedDelete:
+		glog.V(1).Infof("Deleting %s", filename)
+		err = fs.RemoveAll(fs.Join(exportDir, filename))
+	case removedArchive:
+		archived := fs.Join(archiveDirectory, filename)
+		glog.V(1).Infof("Moving %s to %s", filename, archived)
+		if err = fs.MkdirAll(fs.Join(exportDir, fs.Join(archiveDirectory, mediaDirectory)), 0755); err != nil {
+			return err
+		}
+		err = fs.Rename(fs.Join(exportDir, filename), fs.Join(exportDir, archived))
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/filename"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+var testFiles = []mediawiki.File{
+	{Name: "Diagram.png", Title: "File:Diagram.png", URL: "http://wiki.example.org/images/a/ab/Diagram.png", Size: 5, SHA1: "aa"},
+	{Name: "Plan v2.pdf", Title: "File:Plan v2.pdf", URL: "http://wiki.example.org/images/c/cd/Plan_v2.pdf", Size: 6, SHA1: "bb"},
+	{Name: "Photo.jpg", Title: "File:Photo.jpg", URL: "http://wiki.example.org/images/e/ef/Photo.jpg", Size: 7, SHA1: "cc"},
+}
+
+func TestExportFiles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListFilesContext(gomock.Any()).Return(testFiles, nil)
+	written := make(map[string]string)
+	mockFileSystem := NewMockfileSystem(mockCtrl)
+	mockFileSystem.EXPECT().Join(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(dir, name string) string {
+		return filepath.Join(dir, name)
+	})
+	mockFileSystem.EXPECT().MkdirAll(filepath.Join("outputFolder", "_files"), os.FileMode(0755)).Return(nil)
+	mockFileSystem.EXPECT().WriteStream(gomock.Any(), os.FileMode(0644), gomock.Any()).Times(2).DoAndReturn(func(filename string, perm os.FileMode, write func(w io.Writer) error) error {
+		var content bytes.Buffer
+		err := write(&content)
+		written[filename] = content.String()
+		return err
+	})
+	// Only the changed and new files are downloaded
+	mockClient.EXPECT().DownloadFileContext(gomock.Any(), testFiles[1], gomock.Any()).DoAndReturn(func(ctx context.Context, file mediawiki.File, w io.Writer) error {
+		_, err := w.Write([]byte("Plan 2"))
+		return err
+	})
+	mockClient.EXPECT().DownloadFileContext(gomock.Any(), testFiles[2], gomock.Any()).DoAndReturn(func(ctx context.Context, file mediawiki.File, w io.Writer) error {
+		_, err := w.Write([]byte("A photo"))
+		return err
+	})
+	mockFileSystem.EXPECT().MkdirAll(filepath.Join("outputFolder", "_deleted", "_files"), os.FileMode(0755)).Return(nil)
+	mockFileSystem.EXPECT().Rename(filepath.Join("outputFolder", "_files", "Old.gif"), filepath.Join("outputFolder", "_deleted", "_files", "Old.gif")).Return(nil)
+
+	// Unchanged files from older exports, which kept them in files, are moved rather than downloaded again
+	mockFileSystem.EXPECT().Rename(filepath.Join("outputFolder", "files", "Diagram.png"), filepath.Join("outputFolder", "_files", "Diagram.png")).Return(nil)
+
+	previous := &manifest{Files: []manifestFile{
+		{Name: "Diagram.png", Size: 5, SHA1: "aa", Filename: filepath.Join("files", "Diagram.png")},
+		{Name: "Plan v2.pdf", Size: 4, SHA1: "b0", Filename: filepath.Join("_files", "Plan_v2.pdf")},
+		{Name: "Old.gif", Size: 3, SHA1: "dd", Filename: filepath.Join("_files", "Old.gif")},
+	}}
+	options := exportOptions{Encoder: filename.Percent{}, Removed: removedArchive, Concurrency: 2}
+	var summary exportSummary
+	files, err := exportFiles(context.Background(), mockClient, "outputFolder", previous, options, mockFileSystem, &summary)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedWritten := map[string]string{
+		filepath.Join("outputFolder", "_files", "Plan_v2.pdf"): "Plan 2",
+		filepath.Join("outputFolder", "_files", "Photo.jpg"):   "A photo",
+	}
+	if !reflect.DeepEqual(written, expectedWritten) {
+		t.Errorf("Wrong files written: %v", written)
+	}
+	expected := []manifestFile{
+		{Name: "Diagram.png", Size: 5, SHA1: "aa", Filename: filepath.Join("_files", "Diagram.png")},
+		{Name: "Plan v2.pdf", Size: 6, SHA1: "bb", Filename: filepath.Join("_files", "Plan_v2.pdf")},
+		{Name: "Photo.jpg", Size: 7, SHA1: "cc", Filename: filepath.Join("_files", "Photo.jpg")},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Wrong manifest: %+v", files)
+	}
+	if summary.FilesDownloaded != 2 || summary.FilesUnchanged != 1 || !reflect.DeepEqual(summary.Deleted, []string{"Old.gif (uploaded file)"}) {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+}
+
+func TestExportFilesDownloadFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListFilesContext(gomock.Any()).Return(testFiles[:1], nil)
+	checksumError := &mediawiki.ChecksumError{Name: "Diagram.png", Expected: "SHA-1 aa", Actual: "SHA-1 ab"}
+	mockClient.EXPECT().DownloadFileContext(gomock.Any(), testFiles[0], gomock.Any()).Return(checksumError)
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll(filepath.Join("outputFolder", "_files"), os.FileMode(0755)).Return(nil)
+	mockFileSystem.EXPECT().WriteStream(filepath.Join("outputFolder", "_files", "Diagram.png"), os.FileMode(0644), gomock.Any()).DoAndReturn(func(filename string, perm os.FileMode, write func(w io.Writer) error) error {
+		return write(&bytes.Buffer{})
+	})
+
+	options := exportOptions{Encoder: filename.Percent{}}
+	_, err := exportFiles(context.Background(), mockClient, "outputFolder", nil, options, mockFileSystem, &exportSummary{})
+	if err != checksumError {
+		t.Errorf("Expected the checksum error: %v", err)
+	}
+}
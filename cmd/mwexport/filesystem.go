@@ -1,16 +1,71 @@
 package main
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 type fileSystem interface {
+	ReadFile(filename string) ([]byte, error)
 	WriteFile(filename string, data []byte, perm os.FileMode) error
+	WriteStream(filename string, perm os.FileMode, write func(w io.Writer) error) error
+	MkdirAll(path string, perm os.FileMode) error
+	Rename(oldpath, newpath string) error
+	RemoveAll(path string) error
+	Join(dir, name string) string
 }
 
 type localFileSystem struct{}
 
-func (localFileSystem) WriteFile(filename string, data []byte, perm os.FileMode) error {
-	return ioutil.WriteFile(filename, data, perm)
+func (localFileSystem) ReadFile(filename string) ([]byte, error) {
+	return ioutil.ReadFile(filename)
+}
+
+// Write the file atomically, so an interrupted export never leaves a half-written file behind
+func (fs localFileSystem) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	return fs.WriteStream(filename, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// Write the file atomically from whatever write produces, without holding it all in memory. Nothing is written if
+// write fails
+func (localFileSystem) WriteStream(filename string, perm os.FileMode, write func(w io.Writer) error) error {
+	temp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	err = write(temp)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+func (localFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (localFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (localFileSystem) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (localFileSystem) Join(dir, name string) string {
+	return filepath.Join(dir, name)
 }
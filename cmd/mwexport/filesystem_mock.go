@@ -5,6 +5,7 @@ package main
 
 import (
 	gomock "github.com/golang/mock/gomock"
+	io "io"
 	os "os"
 )
 
@@ -29,6 +30,17 @@ func (_m *MockfileSystem) EXPECT() *_MockfileSystemRecorder {
 	return _m.recorder
 }
 
+func (_m *MockfileSystem) ReadFile(filename string) ([]byte, error) {
+	ret := _m.ctrl.Call(_m, "ReadFile", filename)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockfileSystemRecorder) ReadFile(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ReadFile", arg0)
+}
+
 func (_m *MockfileSystem) WriteFile(filename string, data []byte, perm os.FileMode) error {
 	ret := _m.ctrl.Call(_m, "WriteFile", filename, data, perm)
 	ret0, _ := ret[0].(error)
@@ -38,3 +50,53 @@ func (_m *MockfileSystem) WriteFile(filename string, data []byte, perm os.FileMo
 func (_mr *_MockfileSystemRecorder) WriteFile(arg0, arg1, arg2 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "WriteFile", arg0, arg1, arg2)
 }
+
+func (_m *MockfileSystem) WriteStream(filename string, perm os.FileMode, write func(w io.Writer) error) error {
+	ret := _m.ctrl.Call(_m, "WriteStream", filename, perm, write)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockfileSystemRecorder) WriteStream(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "WriteStream", arg0, arg1, arg2)
+}
+
+func (_m *MockfileSystem) MkdirAll(path string, perm os.FileMode) error {
+	ret := _m.ctrl.Call(_m, "MkdirAll", path, perm)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockfileSystemRecorder) MkdirAll(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "MkdirAll", arg0, arg1)
+}
+
+func (_m *MockfileSystem) Rename(oldpath string, newpath string) error {
+	ret := _m.ctrl.Call(_m, "Rename", oldpath, newpath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockfileSystemRecorder) Rename(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Rename", arg0, arg1)
+}
+
+func (_m *MockfileSystem) RemoveAll(path string) error {
+	ret := _m.ctrl.Call(_m, "RemoveAll", path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockfileSystemRecorder) RemoveAll(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RemoveAll", arg0)
+}
+
+func (_m *MockfileSystem) Join(dir string, name string) string {
+	ret := _m.ctrl.Call(_m, "Join", dir, name)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+func (_mr *_MockfileSystemRecorder) Join(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Join", arg0, arg1)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs := localFileSystem{}
+	path := fs.Join(dir, "Article.txt")
+	for _, content := range []string{"First version", "Second"} {
+		if err = fs.WriteFile(path, []byte(content), 0640); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Errorf("Wrong content <%s>: %v", data, err)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("Wrong permissions: %v %v", info, err)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Temporary files were left behind: %v %v", entries, err)
+	}
+
+	if err = fs.WriteFile(filepath.Join(dir, "missing", "Article.txt"), []byte("content"), 0644); err == nil {
+		t.Errorf("Should have failed writing into a missing directory")
+	}
+}
+
+func TestLocalWriteStreamFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs := localFileSystem{}
+	path := fs.Join(dir, "Diagram.png")
+	failure := errors.New("connection reset")
+	err = fs.WriteStream(path, 0644, func(w io.Writer) error {
+		w.Write([]byte("half a file"))
+		return failure
+	})
+	if err != failure {
+		t.Errorf("Expected the write error: %v", err)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Files were left behind: %v %v", entries, err)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/stevearm/mediawiki-export/gitrepo"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+// Where the export goes
+const (
+	outputFiles = "files" // A directory of text files holding the latest revision of each article
+	outputGit   = "git"   // A bare git repository with a commit for every revision, see exportGit
+	outputXML   = "xml"   // A MediaWiki XML dump, see exportXML
+)
+
+// Check an output format given on the command line
+func checkOutputFormat(format string) error {
+	switch format {
+	case outputFiles, outputGit, outputXML:
+		return nil
+	}
+	return fmt.Errorf("Unknown output format: %s", format)
+}
+
+// A revision waiting to be committed, whose content has already been stored in the repository
+type gitRevision struct {
+	Title    string
+	Revision mediawiki.Revision // Without its content
+	Blob     gitrepo.Hash
+}
+
+// Export the wiki into a bare git repository at repoDir, with a commit for every revision in the order they were saved
+func exportGit(ctx context.Context, wiki source, repoDir string, options exportOptions, fs fileSystem) (exportSummary, error) {
+	var summary exportSummary
+	titles, namespacesByID, namespaceIDs, err := listArticles(ctx, wiki, options.Namespaces)
+	if err != nil {
+		return summary, err
+	}
+	filenames, _, err := articleFilenames(titles, namespacesByID, repoDir, options, joinSlash)
+	if err != nil {
+		return summary, err
+	}
+	repo, err := gitrepo.Open(repoDir)
+	if err != nil {
+		return summary, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return summary, fmt.Errorf("Cannot read the branch of %s: %v", repoDir, err)
+	}
+	previous, err := readManifest(fs, repoDir)
+	if err != nil {
+		return summary, err
+	}
+	switch {
+	case previous == nil && !head.IsZero():
+		return summary, fmt.Errorf("Git repository %s already has commits that were not exported by mwexport", repoDir)
+	case previous != nil && previous.Site != options.Site:
+		return summary, fmt.Errorf("Git repository %s holds the history of %s", repoDir, previous.Site)
+	case previous != nil && previous.Head != head.String():
+		return summary, fmt.Errorf("Git repository %s has commits that mwexport did not record", repoDir)
+	}
+	tree := gitrepo.NewTree()
+	if !head.IsZero() {
+		commit, err := repo.ReadCommit(head)
+		if err != nil {
+			return summary, err
+		}
+		if tree, err = repo.ReadTree(commit.Tree); err != nil {
+			return summary, err
+		}
+	}
+	started := now()
+	changed, err := findChangedTitles(ctx, wiki, previous, namespaceIDs, options, started)
+	if err != nil {
+		return summary, err
+	}
+
+	// What the repository holds of each listed article, which moved articles carry over from their old title
+	current := make(map[string]manifestPage)
+	var removed, unselected []manifestPage
+	if previous != nil {
+		for _, page := range previous.Pages {
+			if _, selected := namespacesByID[page.Namespace]; !selected {
+				unselected = append(unselected, page)
+			} else if _, listed := filenames[page.Title]; listed {
+				current[page.Title] = page
+			} else {
+				removed = append(removed, page)
+			}
+		}
+	}
+	var deleted []manifestPage
+	moved := make(map[string]struct{})
+	if len(removed) > 0 {
+		movedTo, deletions, err := traceRemovals(ctx, wiki, previous, removed, filenames)
+		if err != nil {
+			return summary, err
+		}
+		for _, page := range removed {
+			target, found := movedTo[page.Title]
+			if _, taken := current[target]; found && !taken {
+				summary.Moved = append(summary.Moved, fmt.Sprintf("%s -> %s", page.Title, target))
+				page.Title = target
+				current[target] = page
+				moved[target] = struct{}{}
+				continue
+			}
+			if _, found := deletions[page.Title]; found {
+				summary.Deleted = append(summary.Deleted, page.Title)
+			} else {
+				summary.Deleted = append(summary.Deleted, page.Title+" (no longer listed)")
+			}
+			deleted = append(deleted, page)
+		}
+	}
+
+	// Moves don't show up as changes of the new title, so moved articles are always checked for new revisions
+	var outdated []mediawiki.Title
+	for _, title := range titles {
+		_, known := current[title.Title]
+		_, isChanged := changed[title.Title]
+		_, isMoved := moved[title.Title]
+		if changed == nil || !known || isChanged || isMoved {
+			outdated = append(outdated, title)
+		}
+	}
+	glog.Infof("Fetching the history of %d of %d articles", len(outdated), len(titles))
+	revisions, err := fetchRevisions(ctx, wiki, repo, outdated, current, options)
+	if err != nil {
+		return summary, err
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		a, b := revisions[i].Revision, revisions[j].Revision
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		return a.ID < b.ID
+	})
+
+	host := siteHost(options.Site)
+	// The title whose content is at each path, so a path taken over by another article is left alone
+	owners := make(map[string]string)
+	for _, page := range deleted {
+		owners[page.Filename] = page.Title
+	}
+	for title, page := range current {
+		owners[page.Filename] = title
+	}
+	// Move an article to its current filename, unless another article has taken over its old path
+	place := func(title string, blob gitrepo.Hash) {
+		page := current[title]
+		filename := filenames[title]
+		if page.Filename != "" && page.Filename != filename && owners[page.Filename] == title {
+			tree.Remove(page.Filename)
+			delete(owners, page.Filename)
+		}
+		tree.Set(filename, blob)
+		owners[filename] = title
+	}
+	parent := head
+	updated := make(map[string]struct{})
+	for _, revision := range revisions {
+		place(revision.Title, revision.Blob)
+		treeHash, err := repo.WriteTree(tree)
+		if err != nil {
+			return summary, err
+		}
+		author := gitrepo.Signature{
+			Name:  gitAuthorName(revision.Revision.User),
+			Email: gitAuthorEmail(revision.Revision.User, host),
+			When:  revision.Revision.Timestamp,
+		}
+		parent, err = repo.WriteCommit(treeHash, parent, author, gitCommitMessage(revision.Title, revision.Revision))
+		if err != nil {
+			return summary, err
+		}
+		page := current[revision.Title]
+		page.Title = revision.Title
+		page.RevisionID = revision.Revision.ID
+		page.SHA1 = revision.Revision.SHA1
+		page.Filename = filenames[revision.Title]
+		current[revision.Title] = page
+		updated[revision.Title] = struct{}{}
+	}
+
+	// Tidy up what no revision dealt with: deleted articles, and moved or renamed ones without a new revision
+	tidied := false
+	if options.Removed == removedDelete || options.Removed == removedArchive {
+		for _, page := range deleted {
+			if owners[page.Filename] == page.Title {
+				tidied = tree.Remove(page.Filename) || tidied
+				delete(owners, page.Filename)
+			}
+		}
+	}
+	for _, title := range titles {
+		page := current[title.Title]
+		if page.Filename == "" || page.Filename == filenames[title.Title] {
+			continue
+		}
+		if blob, found := tree.Get(page.Filename); found && owners[page.Filename] == title.Title {
+			place(title.Title, blob)
+			tidied = true
+		}
+		page.Filename = filenames[title.Title]
+		current[title.Title] = page
+	}
+	if tidied {
+		treeHash, err := repo.WriteTree(tree)
+		if err != nil {
+			return summary, err
+		}
+		author := gitrepo.Signature{Name: "mwexport", Email: "mwexport@" + host, When: started}
+		parent, err = repo.WriteCommit(treeHash, parent, author, "Remove deleted articles and rename moved ones")
+		if err != nil {
+			return summary, err
+		}
+	}
+	if parent != head {
+		if err = repo.SetHead(parent); err != nil {
+			return summary, fmt.Errorf("Cannot update the branch of %s: %v", repoDir, err)
+		}
+	}
+	summary.Downloaded = len(updated)
+	summary.Unchanged = len(titles) - len(updated)
+	summary.Revisions = len(revisions)
+
+	result := manifest{Site: options.Site, Started: started, Metadata: options.Metadata, History: options.History}
+	if !parent.IsZero() {
+		result.Head = parent.String()
+	}
+	for _, title := range titles {
+		if page, found := current[title.Title]; found {
+			page.Namespace = title.Namespace
+			page.PageID = title.PageID
+			result.Pages = append(result.Pages, page)
+		}
+	}
+	result.Pages = append(result.Pages, unselected...)
+	return summary, writeManifest(fs, repoDir, result)
+}
+
+// Fetch the revisions of each article saved after the one in the repository, using a pool of workers. Their content
+// goes straight into the repository, so only the details of each revision are kept in memory
+func fetchRevisions(ctx context.Context, wiki source, repo *gitrepo.Repository, titles []mediawiki.Title, current map[string]manifestPage, options exportOptions) ([]gitRevision, error) {
+	results := make([][]gitRevision, len(titles))
+	err := runWorkers(ctx, options.Concurrency, len(titles), func(index int) error {
+		title := titles[index]
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		revisions, err := revisionsSince(ctx, wiki, title, current[title.Title])
+		if err != nil {
+			return err
+		}
+		for _, revision := range revisions {
+			blob, err := repo.WriteBlob([]byte(revision.Content))
+			if err != nil {
+				return err
+			}
+			revision.Content = ""
+			results[index] = append(results[index], gitRevision{Title: title.Title, Revision: revision, Blob: blob})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var revisions []gitRevision
+	for _, result := range results {
+		revisions = append(revisions, result...)
+	}
+	return revisions, nil
+}
+
+// The revisions of an article saved after the last one committed. When that revision is gone, because the article was
+// deleted and created again or the revision itself was deleted, the whole history is fetched and filtered instead
+func revisionsSince(ctx context.Context, wiki source, title mediawiki.Title, committed manifestPage) ([]mediawiki.Revision, error) {
+	recreated := committed.PageID != 0 && title.PageID != 0 && committed.PageID != title.PageID
+	if !recreated || committed.RevisionID == 0 {
+		revisions, err := wiki.GetHistoryAfterContext(ctx, title.Title, committed.RevisionID)
+		if !isMissingRevision(err) {
+			return revisions, err
+		}
+	}
+	glog.Warningf("Revision %d of %s is gone, fetching its whole history", committed.RevisionID, title.Title)
+	revisions, err := wiki.GetHistoryContext(ctx, title.Title)
+	if err != nil {
+		return nil, err
+	}
+	var newer []mediawiki.Revision
+	for _, revision := range revisions {
+		if revision.ID > committed.RevisionID {
+			newer = append(newer, revision)
+		}
+	}
+	return newer, nil
+}
+
+// Whether the api refused to start a history at a revision because it no longer exists, or is not of that page
+func isMissingRevision(err error) bool {
+	var apiErr *mediawiki.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case "badid", "badid_rvstartid", "nosuchrevid", "revwrongpage":
+		return true
+	}
+	return false
+}
+
+// Git always separates directories with a slash
+func joinSlash(dir, name string) string {
+	return path.Join(dir, name)
+}
+
+// The host name of the wiki, which commit authors get their email address at
+func siteHost(site string) string {
+	if parsed, err := url.Parse(site); err == nil && parsed.Hostname() != "" {
+		return parsed.Hostname()
+	}
+	return "wiki"
+}
+
+// Revisions whose user was hidden by an administrator come back without one
+func gitAuthorName(user string) string {
+	if user == "" {
+		return "Unknown user"
+	}
+	return user
+}
+
+// Wiki users have no email address in the api, so each gets a made up one that git tools can tell them apart by
+func gitAuthorEmail(user string, host string) string {
+	if user == "" {
+		return "unknown@" + host
+	}
+	return strings.ReplaceAll(user, " ", "_") + "@" + host
+}
+
+// The edit summary of a revision, followed by trailers that tie the commit back to the wiki
+func gitCommitMessage(title string, revision mediawiki.Revision) string {
+	subject := strings.TrimSpace(revision.Comment)
+	if subject == "" {
+		subject = "Edit " + title
+	}
+	return fmt.Sprintf("%s\n\nWiki-Title: %s\nWiki-Revision: %d\n", subject, title, revision.ID)
+}
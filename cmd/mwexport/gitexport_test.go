@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/filename"
+	"github.com/stevearm/mediawiki-export/gitrepo"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+var testGitOptions = exportOptions{Encoder: filename.Percent{}, Site: "http://wiki.example.org/api.php", Removed: removedDelete}
+
+func testRevision(id int, user string, day int, comment string, content string) mediawiki.Revision {
+	return mediawiki.Revision{ID: id, User: user, Comment: comment, Timestamp: time.Date(2020, 1, day, 0, 0, 0, 0, time.UTC), SHA1: "sha" + content, Content: content}
+}
+
+// The files at each commit on the branch, oldest first, along with the commit messages
+func readGitHistory(t *testing.T, dir string) ([]map[string]string, []string) {
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var snapshots []map[string]string
+	var messages []string
+	hash, err := repo.Head()
+	for err == nil && !hash.IsZero() {
+		var commit gitrepo.Commit
+		var tree *gitrepo.Tree
+		if commit, err = repo.ReadCommit(hash); err != nil {
+			break
+		}
+		if tree, err = repo.ReadTree(commit.Tree); err != nil {
+			break
+		}
+		files := make(map[string]string)
+		for path, blob := range tree.Files() {
+			content, _ := repo.ReadBlob(blob)
+			files[path] = string(content)
+		}
+		snapshots = append([]map[string]string{files}, snapshots...)
+		messages = append([]string{commit.Message}, messages...)
+		hash = commit.Parent
+	}
+	if err != nil {
+		t.Fatalf("Cannot read history: %v", err)
+	}
+	return snapshots, messages
+}
+
+func TestExportGit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	pinNow(t)
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The first run replays every revision of both articles in the order they were saved
+	mockClient := newMockListing(mockCtrl, []mediawiki.Title{
+		{Namespace: 0, Title: "Home"},
+		{Namespace: 100, Title: "Recipe:Cake"},
+	})
+	mockClient.EXPECT().GetHistoryAfterContext(gomock.Any(), "Home", 0).Return([]mediawiki.Revision{
+		testRevision(10, "Alice", 1, "Created page", "Welcome"),
+		testRevision(12, "Bob", 3, "", "Welcome!"),
+	}, nil)
+	mockClient.EXPECT().GetHistoryAfterContext(gomock.Any(), "Recipe:Cake", 0).Return([]mediawiki.Revision{
+		testRevision(11, "Carol Baker", 2, "Flour", "Flour"),
+	}, nil)
+	summary, err := exportGit(context.Background(), mockClient, dir, testGitOptions, localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.Downloaded != 2 || summary.Revisions != 3 {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+	snapshots, messages := readGitHistory(t, dir)
+	expected := []map[string]string{
+		{"Main/Home.txt": "Welcome"},
+		{"Main/Home.txt": "Welcome", "Recipe/Cake.txt": "Flour"},
+		{"Main/Home.txt": "Welcome!", "Recipe/Cake.txt": "Flour"},
+	}
+	if !reflect.DeepEqual(snapshots, expected) {
+		t.Errorf("Wrong commits: %v", snapshots)
+	}
+	if len(messages) != 3 || messages[1] != "Flour\n\nWiki-Title: Recipe:Cake\nWiki-Revision: 11\n" || !strings.HasPrefix(messages[2], "Edit Home\n") {
+		t.Errorf("Wrong messages: %q", messages)
+	}
+
+	// The next run only adds what changed since, and removes the deleted article
+	now = func() time.Time { return testStarted.Add(24 * time.Hour) }
+	mockClient = newMockListing(mockCtrl, []mediawiki.Title{
+		{Namespace: 0, Title: "Home"},
+	})
+	mockClient.EXPECT().RecentChangesContext(gomock.Any(), testStarted.Add(-manifestOverlap), []int{0, 100}).Return([]mediawiki.Change{
+		{Type: "edit", Title: "Home", RevisionID: 13},
+		{Type: "log", Title: "Recipe:Cake", LogType: "delete", LogAction: "delete"},
+	}, nil)
+	mockClient.EXPECT().LogEventsContext(gomock.Any(), "move", testStarted.Add(-manifestOverlap)).Return(nil, nil)
+	mockClient.EXPECT().LogEventsContext(gomock.Any(), "delete", testStarted.Add(-manifestOverlap)).Return([]mediawiki.LogEvent{
+		{Type: "delete", Action: "delete", Title: "Recipe:Cake"},
+	}, nil)
+	mockClient.EXPECT().GetHistoryAfterContext(gomock.Any(), "Home", 12).Return([]mediawiki.Revision{
+		testRevision(13, "Alice", 4, "Tidy", "Hello"),
+	}, nil)
+	summary, err = exportGit(context.Background(), mockClient, dir, testGitOptions, localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.Revisions != 1 || !reflect.DeepEqual(summary.Deleted, []string{"Recipe:Cake"}) {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+	snapshots, messages = readGitHistory(t, dir)
+	expected = append(expected, map[string]string{"Main/Home.txt": "Hello", "Recipe/Cake.txt": "Flour"}, map[string]string{"Main/Home.txt": "Hello"})
+	if !reflect.DeepEqual(snapshots, expected) {
+		t.Errorf("Wrong commits: %v", snapshots)
+	}
+	if len(messages) != 5 || messages[4] != "Remove deleted articles and rename moved ones\n" {
+		t.Errorf("Wrong messages: %q", messages)
+	}
+}
+
+func TestExportGitMovedArticle(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	pinNow(t)
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil).Times(2)
+	gomock.InOrder(
+		mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{{Namespace: 0, Title: "Cake"}}, nil),
+		mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return([]mediawiki.Title{{Namespace: 100, Title: "Recipe:Cake"}}, nil),
+	)
+	mockClient.EXPECT().GetHistoryAfterContext(gomock.Any(), "Cake", 0).Return([]mediawiki.Revision{
+		testRevision(10, "Alice", 1, "Created page", "Flour"),
+	}, nil)
+	if _, err = exportGit(context.Background(), mockClient, dir, testGitOptions, localFileSystem{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The moved article carries on from the last revision under its old title
+	mockClient.EXPECT().RecentChangesContext(gomock.Any(), gomock.Any(), []int{0, 100}).Return(nil, nil)
+	mockClient.EXPECT().LogEventsContext(gomock.Any(), "move", gomock.Any()).Return([]mediawiki.LogEvent{
+		{Type: "move", Action: "move", Title: "Cake", Target: "Recipe:Cake"},
+	}, nil)
+	mockClient.EXPECT().LogEventsContext(gomock.Any(), "delete", gomock.Any()).Return(nil, nil)
+	mockClient.EXPECT().GetHistoryAfterContext(gomock.Any(), "Recipe:Cake", 10).Return([]mediawiki.Revision{
+		testRevision(11, "Bob", 2, "Moved page [[Cake]] to [[Recipe:Cake]]", "Flour"),
+	}, nil)
+	summary, err := exportGit(context.Background(), mockClient, dir, testGitOptions, localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(summary.Moved, []string{"Cake -> Recipe:Cake"}) || summary.Revisions != 1 {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+	snapshots, _ := readGitHistory(t, dir)
+	expected := []map[string]string{{"Main/Cake.txt": "Flour"}, {"Recipe/Cake.txt": "Flour"}}
+	if !reflect.DeepEqual(snapshots, expected) {
+		t.Errorf("Wrong commits: %v", snapshots)
+	}
+}
+
+func TestExportGitRecreatedArticle(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	pinNow(t)
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mockClient := newMockListing(mockCtrl, []mediawiki.Title{
+		{Namespace: 0, Title: "Home", PageID: 1},
+		{Namespace: 100, Title: "Recipe:Cake", PageID: 2},
+	})
+	mockClient.EXPECT().GetHistoryAfterContext(gomock.Any(), "Home", 0).Return([]mediawiki.Revision{testRevision(10, "Alice", 1, "", "Welcome")}, nil)
+	mockClient.EXPECT().GetHistoryAfterContext(gomock.Any(), "Recipe:Cake", 0).Return([]mediawiki.Revision{
+		testRevision(11, "Carol", 2, "", "Flour"),
+		testRevision(12, "Spammer", 3, "", "Spam"),
+	}, nil)
+	if _, err = exportGit(context.Background(), mockClient, dir, testGitOptions, localFileSystem{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Home was deleted and created again as a new page, and the spam revision of the cake was deleted
+	now = func() time.Time { return testStarted.Add(24 * time.Hour) }
+	mockClient = newMockListing(mockCtrl, []mediawiki.Title{
+		{Namespace: 0, Title: "Home", PageID: 3},
+		{Namespace: 100, Title: "Recipe:Cake", PageID: 2},
+	})
+	mockClient.EXPECT().RecentChangesContext(gomock.Any(), testStarted.Add(-manifestOverlap), []int{0, 100}).Return([]mediawiki.Change{
+		{Type: "new", Title: "Home", RevisionID: 20},
+		{Type: "edit", Title: "Recipe:Cake", RevisionID: 21},
+	}, nil)
+	mockClient.EXPECT().GetHistoryContext(gomock.Any(), "Home").Return([]mediawiki.Revision{testRevision(20, "Bob", 5, "", "Hello")}, nil)
+	mockClient.EXPECT().GetHistoryAfterContext(gomock.Any(), "Recipe:Cake", 12).Return(nil, &mediawiki.APIError{Code: "nosuchrevid"})
+	mockClient.EXPECT().GetHistoryContext(gomock.Any(), "Recipe:Cake").Return([]mediawiki.Revision{
+		testRevision(11, "Carol", 2, "", "Flour"),
+		testRevision(21, "Carol", 6, "", "Flour and eggs"),
+	}, nil)
+	summary, err := exportGit(context.Background(), mockClient, dir, testGitOptions, localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.Revisions != 2 {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+	snapshots, _ := readGitHistory(t, dir)
+	if last := snapshots[len(snapshots)-1]; len(snapshots) != 5 || !reflect.DeepEqual(last, map[string]string{"Main/Home.txt": "Hello", "Recipe/Cake.txt": "Flour and eggs"}) {
+		t.Errorf("Wrong commits: %v", snapshots)
+	}
+	exported, err := readManifest(localFileSystem{}, dir)
+	if err != nil || exported.byTitle()["Home"].PageID != 3 {
+		t.Errorf("Expected the new page ID in the manifest: %+v %v", exported, err)
+	}
+}
+
+func TestExportGitForeignRepository(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	repo, _ := gitrepo.Open(dir)
+	tree, _ := repo.WriteTree(gitrepo.NewTree())
+	commit, _ := repo.WriteCommit(tree, gitrepo.Hash{}, gitrepo.Signature{Name: "Someone", Email: "someone@example.org"}, "Unrelated")
+	repo.SetHead(commit)
+
+	mockClient := newMockListing(mockCtrl, []mediawiki.Title{{Namespace: 0, Title: "Cake"}})
+	_, err = exportGit(context.Background(), mockClient, dir, testGitOptions, localFileSystem{})
+	if err == nil || !strings.Contains(err.Error(), "not exported by mwexport") {
+		t.Errorf("Expected an error: %v", err)
+	}
+}
+
+func TestGitAuthor(t *testing.T) {
+	if name, email := gitAuthorName("Carol Baker"), gitAuthorEmail("Carol Baker", siteHost("https://wiki.example.org:8443/w/api.php")); name != "Carol Baker" || email != "Carol_Baker@wiki.example.org" {
+		t.Errorf("Wrong author: %s <%s>", name, email)
+	}
+	if name, email := gitAuthorName(""), gitAuthorEmail("", "wiki"); name != "Unknown user" || email != "unknown@wiki" {
+		t.Errorf("Wrong hidden author: %s <%s>", name, email)
+	}
+}
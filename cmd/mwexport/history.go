@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+// How to keep the full history of each article
+const (
+	historyNone  = "none"  // Don't, only keep the latest revision
+	historyFiles = "files" // In a directory next to the article, holding a numbered .txt and .json file per revision
+	historyJSONL = "jsonl" // In a JSON Lines file next to the article, with one revision per line
+)
+
+// Check a history format given on the command line
+func checkHistoryFormat(format string) error {
+	switch format {
+	case historyNone, historyFiles, historyJSONL:
+		return nil
+	}
+	return fmt.Errorf("Unknown history format: %s", format)
+}
+
+// A revision as written to the history. Content is left out of the .json files, as it is in the .txt next to them
+type revisionRecord struct {
+	RevisionID   int       `json:"revid"`
+	ParentID     int       `json:"parentid"`
+	User         string    `json:"user"`
+	Comment      string    `json:"comment"`
+	Minor        bool      `json:"minor"`
+	Timestamp    time.Time `json:"timestamp"`
+	Size         int       `json:"size"`
+	SHA1         string    `json:"sha1"`
+	ContentModel string    `json:"contentmodel"`
+	Content      *string   `json:"content,omitempty"`
+}
+
+func newRevisionRecord(revision mediawiki.Revision, withContent bool) revisionRecord {
+	record := revisionRecord{
+		RevisionID:   revision.ID,
+		ParentID:     revision.ParentID,
+		User:         revision.User,
+		Comment:      revision.Comment,
+		Minor:        revision.Minor,
+		Timestamp:    revision.Timestamp,
+		Size:         revision.Size,
+		SHA1:         revision.SHA1,
+		ContentModel: revision.ContentModel,
+	}
+	if withContent {
+		record.Content = &revision.Content
+	}
+	return record
+}
+
+// Where the history of an article is kept, relative to the export directory. Empty if it isn't
+func historyFilename(articleFilename string, format string) string {
+	base := strings.TrimSuffix(articleFilename, ".txt")
+	switch format {
+	case historyFiles:
+		return base + ".history"
+	case historyJSONL:
+		return base + ".history.jsonl"
+	}
+	return ""
+}
+
+// Download every revision of an article and write them next to it in the given format
+func writeHistory(ctx context.Context, wiki source, fs fileSystem, exportDir string, title string, filename string, format string) error {
+	path := historyFilename(filename, format)
+	if path == "" {
+		return nil
+	}
+	revisions, err := wiki.GetHistoryContext(ctx, title)
+	if err != nil {
+		return err
+	}
+	path = fs.Join(exportDir, path)
+	if format == historyJSONL {
+		var lines bytes.Buffer
+		for _, revision := range revisions {
+			line, err := json.Marshal(newRevisionRecord(revision, true))
+			if err != nil {
+				return err
+			}
+			lines.Write(line)
+			lines.WriteByte('\n')
+		}
+		return fs.WriteFile(path, lines.Bytes(), 0644)
+	}
+	if err = fs.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	// Numbered from the oldest revision, so the files sort in the order they were saved
+	for i, revision := range revisions {
+		base := fs.Join(path, fmt.Sprintf("%06d", i+1))
+		if err = fs.WriteFile(base+".txt", []byte(revision.Content), 0644); err != nil {
+			return err
+		}
+		metadata, err := json.MarshalIndent(newRevisionRecord(revision, false), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = fs.WriteFile(base+".json", append(metadata, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
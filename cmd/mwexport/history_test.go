@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/filename"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+var testHistory = []mediawiki.Revision{
+	{ID: 10, User: "Alice", Comment: "Created page", Timestamp: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Size: 5, SHA1: "aa", ContentModel: "wikitext", Content: "Flour"},
+	{ID: 11, ParentID: 10, User: "Bob", Comment: "More", Minor: true, Timestamp: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Size: 14, SHA1: "bb", ContentModel: "wikitext", Content: "Flour and eggs"},
+}
+
+func expectHistoryExport(mockCtrl *gomock.Controller) (*mediawiki.MockClient, *MockfileSystem) {
+	mockClient := expectExportCake(mockCtrl)
+	mockClient.EXPECT().GetHistoryContext(gomock.Any(), "Recipe:Cake").Return(testHistory, nil)
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Recipe", "Cake.txt"), []byte("Flour and eggs"), os.FileMode(0644)).Return(nil)
+	return mockClient, mockFileSystem
+}
+
+func TestExportHistoryJSONLines(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient, mockFileSystem := expectHistoryExport(mockCtrl)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Recipe", "Cake.history.jsonl"), []byte(
+		`{"revid":10,"parentid":0,"user":"Alice","comment":"Created page","minor":false,"timestamp":"2020-01-01T00:00:00Z","size":5,"sha1":"aa","contentmodel":"wikitext","content":"Flour"}`+"\n"+
+			`{"revid":11,"parentid":10,"user":"Bob","comment":"More","minor":true,"timestamp":"2020-01-02T00:00:00Z","size":14,"sha1":"bb","contentmodel":"wikitext","content":"Flour and eggs"}`+"\n"),
+		os.FileMode(0644)).Return(nil)
+
+	options := exportOptions{Encoder: filename.Percent{}, History: historyJSONL}
+	_, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestExportHistoryFiles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient, mockFileSystem := expectHistoryExport(mockCtrl)
+	historyDir := filepath.Join("outputFolder", "Recipe", "Cake.history")
+	fileMode := os.FileMode(0644)
+	mockFileSystem.EXPECT().MkdirAll(historyDir, os.FileMode(0755)).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join(historyDir, "000001.txt"), []byte("Flour"), fileMode).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join(historyDir, "000001.json"), []byte(`{
+  "revid": 10,
+  "parentid": 0,
+  "user": "Alice",
+  "comment": "Created page",
+  "minor": false,
+  "timestamp": "2020-01-01T00:00:00Z",
+  "size": 5,
+  "sha1": "aa",
+  "contentmodel": "wikitext"
+}
+`), fileMode).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join(historyDir, "000002.txt"), []byte("Flour and eggs"), fileMode).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join(historyDir, "000002.json"), gomock.Any(), fileMode).Return(nil)
+
+	options := exportOptions{Encoder: filename.Percent{}, History: historyFiles}
+	_, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestHistoryFilename(t *testing.T) {
+	article := filepath.Join("Main", "Home.txt")
+	if name := historyFilename(article, historyFiles); name != filepath.Join("Main", "Home.history") {
+		t.Errorf("Wrong directory: %s", name)
+	}
+	if name := historyFilename(article, historyJSONL); name != filepath.Join("Main", "Home.history.jsonl") {
+		t.Errorf("Wrong file: %s", name)
+	}
+	if name := historyFilename(article, historyNone); name != "" {
+		t.Errorf("Should not keep history: %s", name)
+	}
+	if err := checkHistoryFormat("svn"); err == nil {
+		t.Errorf("Should have rejected an unknown format")
+	}
+}
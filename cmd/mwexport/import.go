@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+type importOptions struct {
+	Summary   string // Edit summary of every edit
+	Bot       bool   // Mark the edits as bot edits, keeping them out of recent changes
+	DryRun    bool   // Only work out what would change, without editing anything
+	BatchSize int    // How many articles to compare with the wiki at once. Defaults to defaultBatchSize
+}
+
+type importSummary struct {
+	DryRun    bool
+	Created   []string // Articles that did not exist on the wiki
+	Updated   []string // Articles whose content on the wiki was different
+	Unchanged int      // Articles that already matched
+}
+
+func (s importSummary) String() string {
+	created, updated := "Created", "Updated"
+	lines := []string{fmt.Sprintf("Created %d articles, updated %d, %d unchanged", len(s.Created), len(s.Updated), s.Unchanged)}
+	if s.DryRun {
+		created, updated = "Would create", "Would update"
+		lines[0] = fmt.Sprintf("Would create %d articles, update %d, %d unchanged", len(s.Created), len(s.Updated), s.Unchanged)
+	}
+	for _, title := range s.Created {
+		lines = append(lines, created+": "+title)
+	}
+	for _, title := range s.Updated {
+		lines = append(lines, updated+": "+title)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// An article as it was exported, ready to be saved back to a wiki
+type exportedArticle struct {
+	Title   string
+	Content string
+}
+
+// Read the latest content of every article in an export directory. The manifest tells which title each file belongs
+// to, as filenames can't always be turned back into titles. Articles whose file is gone are skipped
+func readExport(fs fileSystem, exportDir string) ([]exportedArticle, error) {
+	exported, err := readManifest(fs, exportDir)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case exported == nil:
+		return nil, fmt.Errorf("No export found in %s", exportDir)
+	case exported.Head != "":
+		return nil, fmt.Errorf("%s is a git export, which can't be imported", exportDir)
+	}
+	var articles []exportedArticle
+	for _, page := range exported.Pages {
+		content, err := readExportedArticle(fs, exportDir, exported, page)
+		if os.IsNotExist(err) {
+			glog.Warningf("Skipping %s, as %s is gone", page.Title, page.Filename)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		articles = append(articles, exportedArticle{Title: page.Title, Content: content})
+	}
+	return articles, nil
+}
+
+// Read the content of an exported article, without any metadata
+func readExportedArticle(fs fileSystem, exportDir string, exported *manifest, page manifestPage) (string, error) {
+	content, err := fs.ReadFile(fs.Join(exportDir, page.Filename))
+	if err != nil {
+		return "", err
+	}
+	if exported.Metadata == metadataFrontMatter {
+		return stripFrontMatter(string(content)), nil
+	}
+	return string(content), nil
+}
+
+// Remove the header written by frontMatter from an article file
+func stripFrontMatter(content string) string {
+	if !strings.HasPrefix(content, "---\n") {
+		return content
+	}
+	end := strings.Index(content[len("---\n"):], "\n---\n")
+	if end < 0 {
+		return content
+	}
+	return content[len("---\n")+end+len("\n---\n"):]
+}
+
+// Save the articles of an export directory back to a wiki, editing only those whose content differs
+func importArticles(ctx context.Context, client mediawiki.Client, exportDir string, options importOptions, fs fileSystem) (importSummary, error) {
+	summary := importSummary{DryRun: options.DryRun}
+	articles, err := readExport(fs, exportDir)
+	if err != nil {
+		return summary, err
+	}
+	batchSize := options.BatchSize
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+	glog.Infof("Comparing %d articles with the wiki", len(articles))
+	for start := 0; start < len(articles); start += batchSize {
+		end := start + batchSize
+		if end > len(articles) {
+			end = len(articles)
+		}
+		batch := articles[start:end]
+		titles := make([]string, len(batch))
+		for i, article := range batch {
+			titles[i] = article.Title
+		}
+		pages, err := client.GetPagesContext(ctx, titles, false)
+		if err != nil {
+			return summary, err
+		}
+		for i, page := range pages {
+			article := batch[i]
+			switch {
+			case page.Invalid:
+				return summary, fmt.Errorf("Invalid title %s: %s", page.Requested, page.InvalidReason)
+			case !page.Missing && sameContent(page.Content, article.Content):
+				summary.Unchanged++
+				continue
+			}
+			if !options.DryRun {
+				if err = ctx.Err(); err != nil {
+					return summary, err
+				}
+				// Edits are checked against what was read, so that changes made on the wiki since are reported as conflicts
+				edit := mediawiki.Edit{
+					Title:          article.Title,
+					Text:           article.Content,
+					Summary:        options.Summary,
+					Bot:            options.Bot,
+					BaseTimestamp:  page.Timestamp,
+					StartTimestamp: page.Fetched,
+					CreateOnly:     page.Missing,
+					NoCreate:       !page.Missing,
+				}
+				if _, err = client.EditContext(ctx, edit); err != nil {
+					return summary, err
+				}
+			}
+			if page.Missing {
+				summary.Created = append(summary.Created, article.Title)
+			} else {
+				summary.Updated = append(summary.Updated, article.Title)
+			}
+		}
+	}
+	return summary, nil
+}
+
+// MediaWiki drops whitespace from the end of the content it saves, so content that only differs there is the same
+func sameContent(wiki, local string) bool {
+	return strings.TrimRight(wiki, " \t\r\n") == strings.TrimRight(local, " \t\r\n")
+}
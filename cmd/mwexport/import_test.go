@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+// Write an export of three articles, as front-matter files
+func writeTestExport(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fs := localFileSystem{}
+	os.MkdirAll(filepath.Join(dir, "Main"), 0755)
+	os.MkdirAll(filepath.Join(dir, "Recipe"), 0755)
+	pages := []mediawiki.Page{
+		{Title: "Home", PageID: 1, Content: "Welcome"},
+		{Title: "About", PageID: 2, Content: "About us\n"},
+		{Title: "Recipe:Cake", Namespace: 100, PageID: 3, Content: "Flour and eggs"},
+	}
+	exported := manifest{Site: "http://old.example.org/api.php", Metadata: metadataFrontMatter}
+	for _, page := range pages {
+		filename := filepath.Join("Main", page.Title+".txt")
+		if page.Namespace == 100 {
+			filename = filepath.Join("Recipe", "Cake.txt")
+		}
+		if err = writeArticle(fs, filepath.Join(dir, filename), page, metadataFrontMatter, ""); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		exported.Pages = append(exported.Pages, manifestPage{Title: page.Title, Namespace: page.Namespace, PageID: page.PageID, Filename: filename})
+	}
+	// Articles whose file was removed by hand are skipped
+	exported.Pages = append(exported.Pages, manifestPage{Title: "Gone", Filename: filepath.Join("Main", "Gone.txt")})
+	if err = writeManifest(fs, dir, exported); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return dir
+}
+
+// When the articles on the wiki were last edited, and when the wiki said they were read
+var (
+	testRevisionTime = time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	testFetchedTime  = time.Date(2020, 6, 2, 4, 0, 0, 0, time.UTC)
+)
+
+func expectImportComparison(mockCtrl *gomock.Controller) *mediawiki.MockClient {
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Home", "About", "Recipe:Cake"}, false).Return([]mediawiki.Page{
+		{Requested: "Home", Title: "Home", Timestamp: testRevisionTime, Content: "Welcome, old version", Fetched: testFetchedTime},
+		{Requested: "About", Title: "About", Content: "About us", Fetched: testFetchedTime},
+		{Requested: "Recipe:Cake", Title: "Recipe:Cake", Missing: true, Fetched: testFetchedTime},
+	}, nil)
+	return mockClient
+}
+
+func TestImport(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir := writeTestExport(t)
+	defer os.RemoveAll(dir)
+
+	mockClient := expectImportComparison(mockCtrl)
+	// Edits are based on what was read, so the wiki reports anything changed since as a conflict
+	gomock.InOrder(
+		mockClient.EXPECT().EditContext(gomock.Any(), mediawiki.Edit{
+			Title: "Home", Text: "Welcome", Summary: "Restored", Bot: true, BaseTimestamp: testRevisionTime, StartTimestamp: testFetchedTime, NoCreate: true,
+		}).Return(mediawiki.EditResult{}, nil),
+		mockClient.EXPECT().EditContext(gomock.Any(), mediawiki.Edit{
+			Title: "Recipe:Cake", Text: "Flour and eggs", Summary: "Restored", Bot: true, StartTimestamp: testFetchedTime, CreateOnly: true,
+		}).Return(mediawiki.EditResult{}, nil),
+	)
+	summary, err := importArticles(context.Background(), mockClient, dir, importOptions{Summary: "Restored", Bot: true}, localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := importSummary{Created: []string{"Recipe:Cake"}, Updated: []string{"Home"}, Unchanged: 1}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir := writeTestExport(t)
+	defer os.RemoveAll(dir)
+
+	mockClient := expectImportComparison(mockCtrl)
+	summary, err := importArticles(context.Background(), mockClient, dir, importOptions{DryRun: true}, localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "Would create 1 articles, update 1, 1 unchanged\nWould create: Recipe:Cake\nWould update: Home"
+	if summary.String() != expected {
+		t.Errorf("Wrong summary:\n%s", summary)
+	}
+}
+
+func TestImportStopsAtFailedEdit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir := writeTestExport(t)
+	defer os.RemoveAll(dir)
+
+	mockClient := expectImportComparison(mockCtrl)
+	mockClient.EXPECT().EditContext(gomock.Any(), gomock.Any()).Return(mediawiki.EditResult{}, errors.New("protectedpage"))
+	summary, err := importArticles(context.Background(), mockClient, dir, importOptions{}, localFileSystem{})
+	if err == nil || err.Error() != "protectedpage" {
+		t.Errorf("Wrong error: %v", err)
+	}
+	if len(summary.Created) != 0 || len(summary.Updated) != 0 {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+}
+
+func TestImportWithoutExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	_, err = importArticles(context.Background(), nil, dir, importOptions{}, localFileSystem{})
+	if err == nil || err.Error() != "No export found in "+dir {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
+
+func TestStripFrontMatter(t *testing.T) {
+	for content, expected := range map[string]string{
+		"---\ntitle: \"A\"\n---\nText":      "Text",
+		"---\ntitle: \"A\"\n---\n":          "",
+		"No header":                         "No header",
+		"---\nUnfinished header\n":          "---\nUnfinished header\n",
+		"---\ntitle: \"A\"\n---\n---\nRule": "---\nRule",
+	} {
+		if actual := stripFrontMatter(content); actual != expected {
+			t.Errorf("Wrong content for %q: %q", content, actual)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Name of the file in the export directory that records what was exported. The leading dot keeps it apart from
+// namespace directories, whose names never start with one
+const manifestFilename = ".mwexport-manifest.json"
+
+// Bumped whenever the manifest changes in a way older versions can't read
+const manifestVersion = 1
+
+// How far before the previous export to look for changes, to cover clock differences with the wiki and edits made
+// while the previous export was listing pages
+const manifestOverlap = 5 * time.Minute
+
+// Lets tests pin the time an export starts
+var now = time.Now
+
+// What a previous export wrote, which lets the next one only download what changed since
+type manifest struct {
+	Version  int            `json:"version"`
+	Site     string         `json:"site"`           // The api url of the wiki the export came from
+	Started  time.Time      `json:"started"`        // When the export started, so later edits are picked up next time
+	Metadata string         `json:"metadata"`       // How the metadata of each article was written
+	History  string         `json:"history"`        // How the history of each article was written
+	Head     string         `json:"head,omitempty"` // The last commit of a git export, see exportGit
+	Pages    []manifestPage `json:"pages"`
+	Files    []manifestFile `json:"files,omitempty"` // Uploaded files, when they are exported
+}
+
+type manifestPage struct {
+	Title      string `json:"title"`
+	Namespace  int    `json:"namespace"`
+	PageID     int    `json:"pageid"`
+	RevisionID int    `json:"revid"`
+	SHA1       string `json:"sha1"`
+	Filename   string `json:"filename"` // Relative to the export directory
+}
+
+type manifestFile struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	SHA1     string `json:"sha1"`
+	Filename string `json:"filename"` // Relative to the export directory
+}
+
+// Read the manifest of the previous export into exportDir. Returns nil if there is none, or it can't be used
+func readManifest(fs fileSystem, exportDir string) (*manifest, error) {
+	data, err := fs.ReadFile(fs.Join(exportDir, manifestFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var previous manifest
+	if err = json.Unmarshal(data, &previous); err != nil {
+		glog.Warningf("Ignoring unreadable manifest: %v", err)
+		return nil, nil
+	}
+	if previous.Version != manifestVersion {
+		glog.Warningf("Ignoring manifest from version %d", previous.Version)
+		return nil, nil
+	}
+	return &previous, nil
+}
+
+// Record what was exported into exportDir
+func writeManifest(fs fileSystem, exportDir string, current manifest) error {
+	current.Version = manifestVersion
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = fs.WriteFile(fs.Join(exportDir, manifestFilename), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("Cannot save manifest: %v", err)
+	}
+	return nil
+}
+
+// The pages of the manifest by title
+func (m *manifest) byTitle() map[string]manifestPage {
+	pages := make(map[string]manifestPage)
+	if m != nil {
+		for _, page := range m.Pages {
+			pages[page.Title] = page
+		}
+	}
+	return pages
+}
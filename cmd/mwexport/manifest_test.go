@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/filename"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+var testStarted = time.Date(2020, 6, 2, 3, 0, 0, 0, time.UTC)
+
+var testManifest = manifest{
+	Version: manifestVersion,
+	Site:    "http://wiki.example.org/api.php",
+	Started: time.Date(2020, 6, 1, 3, 0, 0, 0, time.UTC),
+	Pages: []manifestPage{
+		{Title: "Unchanged", PageID: 1, RevisionID: 10, SHA1: "aa", Filename: filepath.Join("Main", "Unchanged.txt")},
+		{Title: "Edited", PageID: 2, RevisionID: 20, SHA1: "bb", Filename: filepath.Join("Main", "Edited.txt")},
+	},
+}
+
+var testManifestTitles = []mediawiki.Title{
+	{Namespace: 0, Title: "Unchanged"},
+	{Namespace: 0, Title: "Edited"},
+	{Namespace: 0, Title: "Created"},
+}
+
+// Pin the start of the export for the duration of a test
+func pinNow(t *testing.T) {
+	now = func() time.Time { return testStarted }
+	t.Cleanup(func() { now = time.Now })
+}
+
+// A file system holding the given manifest, which expects the updated one to be saved into saved
+func newManifestFileSystem(mockCtrl *gomock.Controller, previous []byte, saved *manifest) *MockfileSystem {
+	mockFileSystem := NewMockfileSystem(mockCtrl)
+	mockFileSystem.EXPECT().Join(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(dir, name string) string {
+		return filepath.Join(dir, name)
+	})
+	manifestPath := filepath.Join("outputFolder", manifestFilename)
+	mockFileSystem.EXPECT().ReadFile(manifestPath).Return(previous, nil)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(manifestPath, gomock.Any(), os.FileMode(0644)).DoAndReturn(func(filename string, data []byte, perm os.FileMode) error {
+		return json.Unmarshal(data, saved)
+	})
+	return mockFileSystem
+}
+
+func TestIncrementalExport(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	pinNow(t)
+
+	mockClient := newMockListing(mockCtrl, testManifestTitles)
+	mockClient.EXPECT().RecentChangesContext(gomock.Any(), testManifest.Started.Add(-manifestOverlap), []int{0, 100}).Return([]mediawiki.Change{
+		{Type: "edit", Title: "Edited", PageID: 2, RevisionID: 21},
+		{Type: "new", Title: "Created", PageID: 3, RevisionID: 30},
+	}, nil)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Edited", "Created"}, false).Return([]mediawiki.Page{
+		{Requested: "Edited", Title: "Edited", PageID: 2, RevisionID: 21, SHA1: "cc", Content: "New text"},
+		{Requested: "Created", Title: "Created", PageID: 3, RevisionID: 30, SHA1: "dd", Content: "Fresh"},
+	}, nil)
+
+	previous, _ := json.Marshal(testManifest)
+	var saved manifest
+	mockFileSystem := newManifestFileSystem(mockCtrl, previous, &saved)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "Edited.txt"), []byte("New text"), os.FileMode(0644)).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "Created.txt"), []byte("Fresh"), os.FileMode(0644)).Return(nil)
+
+	options := exportOptions{Encoder: filename.Percent{}, Site: testManifest.Site}
+	_, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := manifest{
+		Version: manifestVersion,
+		Site:    testManifest.Site,
+		Started: testStarted,
+		Pages: []manifestPage{
+			testManifest.Pages[0],
+			{Title: "Edited", PageID: 2, RevisionID: 21, SHA1: "cc", Filename: filepath.Join("Main", "Edited.txt")},
+			{Title: "Created", PageID: 3, RevisionID: 30, SHA1: "dd", Filename: filepath.Join("Main", "Created.txt")},
+		},
+	}
+	if !reflect.DeepEqual(saved, expected) {
+		t.Errorf("Wrong manifest: %+v", saved)
+	}
+}
+
+// Expect every article to be downloaded again, without asking for recent changes
+func expectFullExport(t *testing.T, previous []byte, options exportOptions) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	pinNow(t)
+
+	mockClient := newMockListing(mockCtrl, testManifestTitles)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Unchanged", "Edited", "Created"}, false).Return([]mediawiki.Page{
+		testPage("Unchanged", "Same"),
+		testPage("Edited", "New text"),
+		testPage("Created", "Fresh"),
+	}, nil)
+
+	var saved manifest
+	mockFileSystem := newManifestFileSystem(mockCtrl, previous, &saved)
+	mockFileSystem.EXPECT().WriteFile(gomock.Any(), gomock.Any(), os.FileMode(0644)).Times(3).Return(nil)
+
+	options.Encoder = filename.Percent{}
+	_, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(saved.Pages) != 3 || !saved.Started.Equal(testStarted) {
+		t.Errorf("Wrong manifest: %+v", saved)
+	}
+}
+
+func TestFullExportWhenForced(t *testing.T) {
+	previous, _ := json.Marshal(testManifest)
+	expectFullExport(t, previous, exportOptions{Site: testManifest.Site, Full: true})
+}
+
+func TestFullExportWhenManifestTooOld(t *testing.T) {
+	previous, _ := json.Marshal(testManifest)
+	expectFullExport(t, previous, exportOptions{Site: testManifest.Site, MaxManifestAge: time.Hour})
+}
+
+func TestFullExportFromAnotherSite(t *testing.T) {
+	previous, _ := json.Marshal(testManifest)
+	expectFullExport(t, previous, exportOptions{Site: "https://other.example.org/w/api.php"})
+}
+
+func TestFullExportWithNewMetadataFormat(t *testing.T) {
+	previous, _ := json.Marshal(testManifest)
+	expectFullExport(t, previous, exportOptions{Site: testManifest.Site, Metadata: metadataFrontMatter})
+}
+
+func TestFullExportWithBrokenManifest(t *testing.T) {
+	expectFullExport(t, []byte("{not json"), exportOptions{Site: testManifest.Site})
+}
+
+func TestFullExportWithNewerManifest(t *testing.T) {
+	newer := testManifest
+	newer.Version = manifestVersion + 1
+	previous, _ := json.Marshal(newer)
+	expectFullExport(t, previous, exportOptions{Site: testManifest.Site})
+}
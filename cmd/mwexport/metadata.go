@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+// Where to put the metadata of each article
+const (
+	metadataNone        = "none"         // Don't keep any
+	metadataJSON        = "json"         // In a .json file next to the .txt one
+	metadataFrontMatter = "front-matter" // In a YAML header at the top of the .txt file
+)
+
+// Check a metadata format given on the command line
+func checkMetadataFormat(format string) error {
+	switch format {
+	case metadataNone, metadataJSON, metadataFrontMatter:
+		return nil
+	}
+	return fmt.Errorf("Unknown metadata format: %s", format)
+}
+
+// What downstream tooling needs to know about an exported article
+type pageMetadata struct {
+	PageID       int       `json:"pageid"`
+	Title        string    `json:"title"`
+	Namespace    int       `json:"namespace"`
+	RevisionID   int       `json:"revid"`
+	User         string    `json:"user"`
+	Timestamp    time.Time `json:"timestamp"`
+	ContentModel string    `json:"contentmodel"`
+	SHA1         string    `json:"sha1"`
+	URL          string    `json:"url,omitempty"` // Where the article is viewed on the wiki, when known
+}
+
+func newPageMetadata(page mediawiki.Page, url string) pageMetadata {
+	return pageMetadata{
+		PageID:       page.PageID,
+		Title:        page.Title,
+		Namespace:    page.Namespace,
+		RevisionID:   page.RevisionID,
+		User:         page.User,
+		Timestamp:    page.Timestamp,
+		ContentModel: page.ContentModel,
+		SHA1:         page.SHA1,
+		URL:          url,
+	}
+}
+
+// Name of the JSON sidecar of an article file
+func sidecarFilename(articleFilename string) string {
+	return strings.TrimSuffix(articleFilename, ".txt") + ".json"
+}
+
+// Write an article to its file, along with its metadata in the given format
+func writeArticle(fs fileSystem, filename string, page mediawiki.Page, format string, url string) error {
+	content := []byte(page.Content)
+	switch format {
+	case metadataJSON:
+		sidecar, err := json.MarshalIndent(newPageMetadata(page, url), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = fs.WriteFile(sidecarFilename(filename), append(sidecar, '\n'), 0644); err != nil {
+			return err
+		}
+	case metadataFrontMatter:
+		content = append(frontMatter(newPageMetadata(page, url)), content...)
+	}
+	return fs.WriteFile(filename, content, 0644)
+}
+
+// Render metadata as a YAML front-matter block, with the same fields as the JSON sidecar. Strings are quoted as JSON,
+// which is also valid YAML and keeps any quotes or colons in titles from being misread
+func frontMatter(metadata pageMetadata) []byte {
+	quote := func(s string) string {
+		quoted, _ := json.Marshal(s)
+		return string(quoted)
+	}
+	var header bytes.Buffer
+	header.WriteString("---\n")
+	fmt.Fprintf(&header, "pageid: %d\n", metadata.PageID)
+	fmt.Fprintf(&header, "title: %s\n", quote(metadata.Title))
+	fmt.Fprintf(&header, "namespace: %d\n", metadata.Namespace)
+	fmt.Fprintf(&header, "revid: %d\n", metadata.RevisionID)
+	fmt.Fprintf(&header, "user: %s\n", quote(metadata.User))
+	fmt.Fprintf(&header, "timestamp: %s\n", quote(metadata.Timestamp.Format(time.RFC3339)))
+	fmt.Fprintf(&header, "contentmodel: %s\n", quote(metadata.ContentModel))
+	fmt.Fprintf(&header, "sha1: %s\n", quote(metadata.SHA1))
+	if metadata.URL != "" {
+		fmt.Fprintf(&header, "url: %s\n", quote(metadata.URL))
+	}
+	header.WriteString("---\n")
+	return header.Bytes()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/filename"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+var testMetadataPage = mediawiki.Page{
+	Requested:    "Recipe:Cake",
+	Title:        "Recipe:Cake",
+	Namespace:    100,
+	PageID:       12,
+	RevisionID:   345,
+	User:         "Alice \"Baker\"",
+	Timestamp:    time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC),
+	ContentModel: "wikitext",
+	SHA1:         "0123abcd",
+	Content:      "Flour and eggs",
+}
+
+func expectExportCake(mockCtrl *gomock.Controller) *mediawiki.MockClient {
+	mockClient := newMockListing(mockCtrl, []mediawiki.Title{
+		{Namespace: 100, Title: "Recipe:Cake"},
+	})
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Recipe:Cake"}, false).Return([]mediawiki.Page{testMetadataPage}, nil)
+	return mockClient
+}
+
+func TestExportJSONMetadata(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := expectExportCake(mockCtrl)
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	fileMode := os.FileMode(0644)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Recipe", "Cake.txt"), []byte("Flour and eggs"), fileMode).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Recipe", "Cake.json"), []byte(`{
+  "pageid": 12,
+  "title": "Recipe:Cake",
+  "namespace": 100,
+  "revid": 345,
+  "user": "Alice \"Baker\"",
+  "timestamp": "2020-03-04T05:06:07Z",
+  "contentmodel": "wikitext",
+  "sha1": "0123abcd"
+}
+`), fileMode).Return(nil)
+
+	options := exportOptions{Encoder: filename.Percent{}, Metadata: metadataJSON}
+	_, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestExportFrontMatter(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockClient := expectExportCake(mockCtrl)
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().MkdirAll(gomock.Any(), os.FileMode(0755)).Times(2).Return(nil)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Recipe", "Cake.txt"), []byte(`---
+pageid: 12
+title: "Recipe:Cake"
+namespace: 100
+revid: 345
+user: "Alice \"Baker\""
+timestamp: "2020-03-04T05:06:07Z"
+contentmodel: "wikitext"
+sha1: "0123abcd"
+url: "https://wiki.example.org/wiki/Recipe:Cake"
+---
+Flour and eggs`), os.FileMode(0644)).Return(nil)
+
+	location := mediawiki.Site{Scheme: "https", Host: "wiki.example.org", ArticlePath: "/wiki/$1"}
+	options := exportOptions{Encoder: filename.Percent{}, Metadata: metadataFrontMatter, Location: location}
+	_, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestCheckMetadataFormat(t *testing.T) {
+	for _, format := range []string{metadataNone, metadataJSON, metadataFrontMatter} {
+		if err := checkMetadataFormat(format); err != nil {
+			t.Errorf("Unexpected error for %s: %v", format, err)
+		}
+	}
+	if err := checkMetadataFormat("yaml"); err == nil {
+		t.Errorf("Should have rejected an unknown format")
+	}
+}
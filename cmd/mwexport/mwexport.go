@@ -1,9 +1,44 @@
 /*
-The mwexport tool exports the whole mediawiki to a local folder. It saves the raw wikitext of each article into a file named after the article's title.
+The mwexport tool exports the whole mediawiki to a local folder. It saves the raw wikitext of each article into a file named after the article's title,
+inside a subdirectory named after the article's namespace. Titles are escaped reversibly to make them safe filenames (see the
+filename package).
 
 Usage:
 
-  mwexport [OPTIONS] host username password exportDir
+  mwexport [export] [OPTIONS] url exportDir
+
+The url is either a bare host, the url of api.php, or the url of any page on the wiki (such as the main page), which is
+used to discover where api.php lives. It can also be the path of a MediaWiki XML dump (ending in .xml, .xml.gz or
+.xml.bz2), which is exported the same way without any network access.
+
+Credentials are never taken from the command line. The password (and the username, unless -user is given) is read from
+the MWEXPORT_USERNAME and MWEXPORT_PASSWORD environment variables, then from a netrc-style credentials file:
+
+  machine wiki.example.org login username password secret
+
+which must not be readable by other users, and finally prompted for when running in a terminal. Use -anonymous to skip
+logging in to public wikis.
+
+To put the articles of an export back on a wiki, such as after losing the server, use the import command:
+
+  mwexport import [OPTIONS] url exportDir
+
+It creates the articles that are missing and edits the ones whose content differs, leaving the others alone. Use
+-dry-run to see what it would change first.
+
+Other commands look at the wiki without exporting anything:
+
+  mwexport list [OPTIONS] url                   the titles of the articles, one per line
+  mwexport get [OPTIONS] url title              the wikitext of an article
+  mwexport status [OPTIONS] url exportDir       the articles created, changed or deleted since exportDir was exported
+  mwexport diff [OPTIONS] url exportDir title   how an exported article differs from the wiki, as a unified diff
+
+They take the same options for connecting and logging in. Running mwexport without a command is the same as
+mwexport export.
+
+With -output git, exportDir is a bare git repository instead, with a commit for every revision of every article. Later
+runs only add the revisions saved since. With -output xml, it is a MediaWiki XML dump file that importDump.php can
+restore.
 */
 package main
 
@@ -11,22 +46,50 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"runtime"
+	"time"
 
+	"github.com/stevearm/mediawiki-export/filename"
 	"github.com/stevearm/mediawiki-export/mediawiki"
 )
 
 var version string
 var build string
 
-func run() error {
-	flag.Set("logtostderr", "true")
+// The commands of mwexport, which each parse their own flags and arguments. Without a command, export is run
+var commands = map[string]func(args []string) error{
+	"export": runExport,
+	"import": runImport,
+	"list":   runList,
+	"get":    runGet,
+	"status": runStatus,
+	"diff":   runDiff,
+}
+
+// Export the wiki into a directory, git repository or XML dump
+func runExport(args []string) error {
+	var flagVersion = flag.Bool("version", false, "show version")
+	var flagNamespaces = flag.String("namespaces", "", "comma separated namespace IDs or names to export, or \"all\" (default: all content namespaces)")
+	var flagFilenames = flag.String("filenames", "percent", "how titles are turned into filenames: percent, percent-ascii or scrub")
+	var flagCaseInsensitive = flag.Bool("case-insensitive", runtime.GOOS == "windows" || runtime.GOOS == "darwin", "treat filenames that differ only by case as the same file")
+	var flagMetadata = flag.String("metadata", metadataJSON, "where to keep the page ID, revision, editor and such of each article: json (a .json file next to it), front-matter (a header in the .txt file) or none")
+	var flagHistory = flag.String("history", historyNone, "also export every revision of each article: files (numbered files in a directory next to it), jsonl (a JSON Lines file next to it) or none")
+	var flagFiles = flag.Bool("files", false, "also download uploaded files, such as images and PDFs, into the "+mediaDirectory+"/ directory")
+	var flagOutput = flag.String("output", outputFiles, "what to export into: files (the latest revision of each article), git (a bare git repository with a commit for every revision, ignoring -metadata and -history) or xml (a MediaWiki XML dump file, compressed if named .gz or .bz2, with every revision if -history is set)")
+	var flagFull = flag.Bool("full", false, "download every article, instead of only those changed since the previous export")
+	var flagMaxAge = flag.Duration("max-age", 30*24*time.Hour, "download every article if the previous export is older than this, as wikis only keep recent changes for a while")
+	var flagRemoved = flag.String("removed", removedArchive, "what to do with the files of articles deleted or moved since the previous export: archive (into "+archiveDirectory+"/), delete or keep. The files of moved articles are renamed unless keeping them")
+	var flagConcurrency = flag.Int("concurrency", 4, "how many batches of articles to download at once")
+	connection := addConnectionFlags()
+	usage := "[export] [OPTIONS] url exportDir"
 	flag.Usage = func() {
-		fmt.Printf("Usage: %s [OPTIONS] host username password exportDir\n", os.Args[0])
+		fmt.Printf("Usage: %s %s\n", os.Args[0], usage)
+		fmt.Printf("       %s import|list|get|status|diff [OPTIONS] ...\n", os.Args[0])
 		flag.PrintDefaults()
 	}
-	var flagVersion = flag.Bool("version", false, "show version")
-	flag.Parse()
+	flag.CommandLine.Parse(args)
 	if *flagVersion {
 		if version == "" {
 			fmt.Fprintf(os.Stderr, "No version found. Rebuild with proper flags:\n")
@@ -37,21 +100,237 @@ func run() error {
 		}
 		return nil
 	}
-	if flag.NArg() != 4 {
-		flag.Usage()
-		return errors.New("")
+	if err := checkArgs(2); err != nil {
+		return err
+	}
+	var (
+		location  = flag.Arg(0)
+		exportDir = flag.Arg(1)
+	)
+	encoder, err := filename.Get(*flagFilenames)
+	if err != nil {
+		return err
+	}
+	if err = checkMetadataFormat(*flagMetadata); err != nil {
+		return err
+	}
+	if err = checkRemovedPolicy(*flagRemoved); err != nil {
+		return err
+	}
+	if err = checkHistoryFormat(*flagHistory); err != nil {
+		return err
+	}
+	if err = checkOutputFormat(*flagOutput); err != nil {
+		return err
+	}
+	if *flagOutput == outputXML {
+		if err = checkDumpCompression(exportDir); err != nil {
+			return err
+		}
+	}
+	if *flagFiles && *flagOutput != outputFiles {
+		return fmt.Errorf("Uploaded files can only be exported with -output %s", outputFiles)
+	}
+	options := exportOptions{
+		Namespaces:      *flagNamespaces,
+		Encoder:         encoder,
+		CaseInsensitive: *flagCaseInsensitive,
+		Concurrency:     *flagConcurrency,
+		Metadata:        *flagMetadata,
+		Full:            *flagFull,
+		MaxManifestAge:  *flagMaxAge,
+		Removed:         *flagRemoved,
+		History:         *flagHistory,
+		Files:           *flagFiles,
+	}
+	if *flagFiles && isDumpFile(location) {
+		return errors.New("XML dumps hold no uploaded files")
+	}
+	opened, err := connection.open(location)
+	if err != nil {
+		return err
+	}
+	defer opened.close()
+	wiki := opened.wiki
+	options.Site = opened.id
+	options.Location = opened.site
+	if _, isDump := wiki.(*dumpSource); isDump {
+		// Dumps have no recent changes to tell what changed since, and are best read in order
+		options.Full = true
+		options.Concurrency = 1
+		options.BatchSize = dumpBatchSize
+	}
+	ctx, stop := commandContext()
+	defer stop()
+	exportTo := export
+	switch *flagOutput {
+	case outputGit:
+		exportTo = exportGit
+	case outputXML:
+		exportTo = exportXML
+	}
+	summary, err := exportTo(ctx, wiki, exportDir, options, localFileSystem{})
+	if err != nil {
+		return err
+	}
+	fmt.Println(summary)
+	return nil
+}
+
+// Flags for finding a wiki and logging in to it, which every command shares
+type connectionFlags struct {
+	discover    *bool
+	clientLogin *bool
+	user        *string
+	anonymous   *bool
+	credentials *string
+	timeout     *time.Duration
+	retries     *int
+	maxLag      *int
+	rate        *float64
+	userAgent   *string
+	articlePath *string
+}
+
+func addConnectionFlags() *connectionFlags {
+	return &connectionFlags{
+		discover:    flag.Bool("discover", true, "find api.php from the EditURI link of the page at url"),
+		clientLogin: flag.Bool("clientlogin", false, "log in with action=clientlogin instead of action=login (bot passwords, given as user@botname, always use action=login)"),
+		user:        flag.String("user", "", "username to log in with (default: $"+usernameEnv+")"),
+		anonymous:   flag.Bool("anonymous", false, "do not log in, for public wikis"),
+		credentials: flag.String("credentials", defaultCredentialsFile(), "netrc-style file to read credentials from"),
+		timeout:     flag.Duration("timeout", time.Minute, "give up on any single HTTP request that takes longer than this"),
+		retries:     flag.Int("retries", mediawiki.DefaultRetryPolicy().Attempts, "how many times to try each HTTP request before giving up on temporary failures"),
+		maxLag:      flag.Int("maxlag", 5, "ask the wiki to refuse requests while its database replicas lag by more than this many seconds (0 to disable)"),
+		rate:        flag.Float64("rate", 5, "most requests per second to send to the wiki (0 for no limit)"),
+		userAgent:   flag.String("user-agent", "", "User-Agent to identify with, ideally including contact details (default: mwexport and its version)"),
+		articlePath: flag.String("article-path", "", "path that pages are viewed under, with $1 for the title (such as /wiki/$1), for the url in the metadata of each article (default: index.php?title=$1)"),
+	}
+}
+
+// Find the wiki at location and get a client for it, which logs in on first use unless -anonymous is given
+func (f *connectionFlags) connect(location string) (mediawiki.Client, mediawiki.Site, error) {
+	userAgent := *f.userAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent()
+	}
+	var site mediawiki.Site
+	var err error
+	if *f.discover {
+		site, err = mediawiki.DiscoverSite(location, &http.Client{Transport: userAgentTransport{userAgent}})
+	} else {
+		site, err = mediawiki.ParseSite(location)
+	}
+	if err != nil {
+		return nil, site, err
+	}
+	site.ArticlePath = *f.articlePath
+	var login credentials
+	if !*f.anonymous {
+		login, err = findCredentials(site.Host, *f.user, localCredentialSources(*f.credentials))
+		if err != nil {
+			return nil, site, err
+		}
+	}
+	retry := mediawiki.DefaultRetryPolicy()
+	retry.Attempts = *f.retries
+	client := mediawiki.GetClient(mediawiki.Config{
+		Site:        site,
+		Username:    login.Username,
+		Password:    login.Password,
+		ClientLogin: *f.clientLogin,
+		Timeout:     *f.timeout,
+		Retry:       retry,
+		MaxLag:      *f.maxLag,
+		RateLimit:   *f.rate,
+		UserAgent:   userAgent,
+	})
+	return client, site, nil
+}
+
+// A wiki or XML dump given on the command line
+type openedSource struct {
+	wiki  source
+	id    string         // Identifies it in a manifest
+	site  mediawiki.Site // Where the wiki is served from. Zero for dumps
+	close func() error
+}
+
+// Open the wiki or XML dump at location
+func (f *connectionFlags) open(location string) (openedSource, error) {
+	if isDumpFile(location) {
+		dump, err := openDump(location)
+		if os.IsNotExist(err) {
+			return openedSource{}, fmt.Errorf("Dump file not found: %s", location)
+		}
+		if err != nil {
+			return openedSource{}, fmt.Errorf("Cannot read %s: %v", location, err)
+		}
+		return openedSource{wiki: dump, id: dump.Site(), close: dump.Close}, nil
+	}
+	client, site, err := f.connect(location)
+	if err != nil {
+		return openedSource{}, err
+	}
+	return openedSource{wiki: client, id: site.ApiUrl(), site: site, close: func() error { return nil }}, nil
+}
+
+// Save the articles of an export directory back to the wiki they came from, or another one
+func runImport(args []string) error {
+	var flagSummary = flag.String("summary", "Restored from an export", "edit summary of every edit")
+	var flagBot = flag.Bool("bot", false, "mark the edits as bot edits, which needs the bot right")
+	var flagDryRun = flag.Bool("dry-run", false, "only print which articles would be created or updated, without editing anything")
+	connection := addConnectionFlags()
+	if err := parseCommand(args, "import [OPTIONS] url exportDir", 2); err != nil {
+		return err
 	}
 	var (
-		host      = flag.Arg(0)
-		username  = flag.Arg(1)
-		password  = flag.Arg(2)
-		exportDir = flag.Arg(3)
+		location  = flag.Arg(0)
+		exportDir = flag.Arg(1)
 	)
-	return export(mediawiki.GetClient(host, username, password), exportDir, localFileSystem{})
+	client, _, err := connection.connect(location)
+	if err != nil {
+		return err
+	}
+	options := importOptions{Summary: *flagSummary, Bot: *flagBot, DryRun: *flagDryRun}
+	ctx, stop := commandContext()
+	defer stop()
+	summary, err := importArticles(ctx, client, exportDir, options, localFileSystem{})
+	// Say what was done even if it stopped part way, so the next run's changes make sense
+	fmt.Println(summary)
+	return err
+}
+
+// Identify as mwexport and the version it was built as, so wiki operators can tell which build is misbehaving
+func defaultUserAgent() string {
+	v := version
+	if v == "" {
+		v = "dev"
+	}
+	return fmt.Sprintf("mwexport/%s (https://github.com/stevearm/mediawiki-export) Go/%s", v, runtime.Version())
+}
+
+// Sets the User-Agent of requests made outside the mediawiki client, such as discovering the site
+type userAgentTransport struct {
+	userAgent string
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return http.DefaultTransport.RoundTrip(req)
 }
 
 func main() {
-	if err := run(); err != nil {
+	flag.Set("logtostderr", "true")
+	// Commands go before any flags, so "mwexport -full url dir" still exports
+	command, args := runExport, os.Args[1:]
+	if len(args) > 0 {
+		if named, found := commands[args[0]]; found {
+			command, args = named, args[1:]
+		}
+	}
+	if err := command(args); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
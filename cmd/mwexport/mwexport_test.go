@@ -0,0 +1,14 @@
+package main
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+// A mock client for a wiki with the test namespaces, listing titles in the main and Recipe namespaces
+func newMockListing(mockCtrl *gomock.Controller, titles []mediawiki.Title) *mediawiki.MockClient {
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().ListTitlesContext(gomock.Any(), []int{0, 100}).Return(titles, nil)
+	return mockClient
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// What to do with the files of articles that were deleted or moved on the wiki since the previous export
+const (
+	removedKeep    = "keep"    // Leave them where they are
+	removedDelete  = "delete"  // Delete the files of deleted articles, and rename those of moved ones
+	removedArchive = "archive" // Move the files of deleted articles into archiveDirectory, and rename those of moved ones
+)
+
+// Where archived files go, keeping their path within the export directory. Encoded namespace names never start with _
+const archiveDirectory = "_deleted"
+
+// Check a policy for removed articles given on the command line
+func checkRemovedPolicy(policy string) error {
+	switch policy {
+	case removedKeep, removedDelete, removedArchive:
+		return nil
+	}
+	return fmt.Errorf("Unknown policy for removed articles: %s", policy)
+}
+
+// What an export changed in the export directory
+type exportSummary struct {
+	Downloaded int      // Articles written
+	Unchanged  int      // Articles skipped as they hadn't changed since the previous export
+	Moved      []string // Moved articles, as "old -> new"
+	Deleted    []string // Articles no longer on the wiki
+	Revisions  int      // Revisions written, when exporting to git or an XML dump
+	// Uploaded files written, and skipped as unchanged, when exporting them
+	FilesDownloaded int
+	FilesUnchanged  int
+}
+
+func (s exportSummary) String() string {
+	lines := []string{fmt.Sprintf("Downloaded %d articles, %d unchanged", s.Downloaded, s.Unchanged)}
+	if s.FilesDownloaded > 0 || s.FilesUnchanged > 0 {
+		lines = append(lines, fmt.Sprintf("Downloaded %d files, %d unchanged", s.FilesDownloaded, s.FilesUnchanged))
+	}
+	if s.Revisions > 0 {
+		lines = append(lines, fmt.Sprintf("Wrote %d revisions", s.Revisions))
+	}
+	for _, moved := range s.Moved {
+		lines = append(lines, "Moved: "+moved)
+	}
+	for _, deleted := range s.Deleted {
+		lines = append(lines, "Deleted: "+deleted)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Delete, archive or rename the files of articles that are no longer listed, before the rest are downloaded
+func propagateRemovals(ctx context.Context, wiki source, exportDir string, previous *manifest, removed []manifestPage, filenames map[string]string, options exportOptions, fs fileSystem, summary *exportSummary) error {
+	if len(removed) == 0 {
+		return nil
+	}
+	movedTo, deleted, err := traceRemovals(ctx, wiki, previous, removed, filenames)
+	if err != nil {
+		return err
+	}
+	for _, page := range removed {
+		if target, found := movedTo[page.Title]; found {
+			summary.Moved = append(summary.Moved, fmt.Sprintf("%s -> %s", page.Title, target))
+			if options.Removed == removedDelete || options.Removed == removedArchive {
+				if err = moveArticleFiles(fs, exportDir, page.Filename, filenames[target], previous); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if _, found := deleted[page.Title]; found {
+			summary.Deleted = append(summary.Deleted, page.Title)
+		} else {
+			summary.Deleted = append(summary.Deleted, page.Title+" (no longer listed)")
+		}
+		switch options.Removed {
+		case removedDelete:
+			err = removeArticleFiles(fs, exportDir, page.Filename, previous)
+		case removedArchive:
+			err = moveArticleFiles(fs, exportDir, page.Filename, fs.Join(archiveDirectory, page.Filename), previous)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Find out what happened to the articles of the previous export that are no longer listed, from the move and delete
+// logs. Returns the title each moved article is listed under now, and the set of deleted titles
+func traceRemovals(ctx context.Context, wiki source, previous *manifest, removed []manifestPage, filenames map[string]string) (map[string]string, map[string]struct{}, error) {
+	since := previous.Started.Add(-manifestOverlap)
+	moves, err := wiki.LogEventsContext(ctx, "move", since)
+	if err != nil {
+		return nil, nil, err
+	}
+	deletions, err := wiki.LogEventsContext(ctx, "delete", since)
+	if err != nil {
+		return nil, nil, err
+	}
+	movedTo := make(map[string]string)
+	for _, event := range moves {
+		if event.Target != "" {
+			movedTo[event.Title] = event.Target
+		}
+	}
+	deleted := make(map[string]struct{})
+	for _, event := range deletions {
+		if event.Action == "delete" {
+			deleted[event.Title] = struct{}{}
+		}
+	}
+	targets := make(map[string]string)
+	for _, page := range removed {
+		// Follow moves until reaching a title that still exists, giving up on loops
+		for title, hops := page.Title, 0; hops <= len(movedTo); hops++ {
+			next, moved := movedTo[title]
+			if !moved {
+				break
+			}
+			if _, found := filenames[next]; found {
+				targets[page.Title] = next
+				break
+			}
+			title = next
+		}
+	}
+	return targets, deleted, nil
+}
+
+// The files (and directories) that hold an article, relative to the export directory
+func articleFiles(filename string, previous *manifest) []string {
+	files := []string{filename}
+	if previous.Metadata == metadataJSON {
+		files = append(files, sidecarFilename(filename))
+	}
+	if history := historyFilename(filename, previous.History); history != "" {
+		files = append(files, history)
+	}
+	return files
+}
+
+// Move the files of an article, ignoring any that are already gone
+func moveArticleFiles(fs fileSystem, exportDir string, from string, to string, previous *manifest) error {
+	glog.V(1).Infof("Moving %s to %s", from, to)
+	if err := fs.MkdirAll(fs.Join(exportDir, filepath.Dir(to)), 0755); err != nil {
+		return err
+	}
+	targets := articleFiles(to, previous)
+	for i, source := range articleFiles(from, previous) {
+		err := fs.Rename(fs.Join(exportDir, source), fs.Join(exportDir, targets[i]))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete the files of an article, ignoring any that are already gone
+func removeArticleFiles(fs fileSystem, exportDir string, filename string, previous *manifest) error {
+	glog.V(1).Infof("Deleting %s", filename)
+	for _, file := range articleFiles(filename, previous) {
+		if err := fs.RemoveAll(fs.Join(exportDir, file)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/filename"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+var testRemovedManifest = manifest{
+	Version: manifestVersion,
+	Site:    testManifest.Site,
+	Started: testManifest.Started,
+	Pages: []manifestPage{
+		{Title: "Unchanged", PageID: 1, RevisionID: 10, Filename: filepath.Join("Main", "Unchanged.txt")},
+		{Title: "Old name", PageID: 2, RevisionID: 20, Filename: filepath.Join("Main", "Old_name.txt")},
+		{Title: "Spam", PageID: 3, RevisionID: 30, Filename: filepath.Join("Main", "Spam.txt")},
+		{Title: "Vanished", PageID: 4, RevisionID: 40, Filename: filepath.Join("Main", "Vanished.txt")},
+		{Title: "Template:Box", Namespace: 10, PageID: 5, RevisionID: 50, Filename: filepath.Join("Template", "Box.txt")},
+	},
+}
+
+// Expect an incremental export where Old name was moved to New name, Spam was deleted and Vanished disappeared
+// without a trace, returning the file system so the test can expect what happens to their files
+func expectRemovals(t *testing.T, mockCtrl *gomock.Controller, saved *manifest) (*mediawiki.MockClient, *MockfileSystem) {
+	pinNow(t)
+	mockClient := newMockListing(mockCtrl, []mediawiki.Title{
+		{Namespace: 0, Title: "Unchanged"},
+		{Namespace: 0, Title: "New name"},
+	})
+	since := testRemovedManifest.Started.Add(-manifestOverlap)
+	mockClient.EXPECT().RecentChangesContext(gomock.Any(), since, []int{0, 100}).Return([]mediawiki.Change{
+		{Type: "log", Title: "Old name", LogType: "move", LogAction: "move"},
+		{Type: "log", Title: "Spam", LogType: "delete", LogAction: "delete"},
+	}, nil)
+	mockClient.EXPECT().LogEventsContext(gomock.Any(), "move", since).Return([]mediawiki.LogEvent{
+		{Type: "move", Action: "move", Title: "Old name", Target: "Newer name"},
+		{Type: "move", Action: "move", Title: "Newer name", Target: "New name"},
+	}, nil)
+	mockClient.EXPECT().LogEventsContext(gomock.Any(), "delete", since).Return([]mediawiki.LogEvent{
+		{Type: "delete", Action: "delete", Title: "Spam"},
+	}, nil)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"New name"}, false).Return([]mediawiki.Page{
+		{Requested: "New name", Title: "New name", PageID: 2, RevisionID: 21, Content: "Moved"},
+	}, nil)
+
+	previous, _ := json.Marshal(testRemovedManifest)
+	mockFileSystem := newManifestFileSystem(mockCtrl, previous, saved)
+	mockFileSystem.EXPECT().WriteFile(filepath.Join("outputFolder", "Main", "New_name.txt"), []byte("Moved"), os.FileMode(0644)).Return(nil)
+	return mockClient, mockFileSystem
+}
+
+func checkRemovals(t *testing.T, summary exportSummary, saved manifest) {
+	expected := exportSummary{
+		Downloaded: 1,
+		Unchanged:  1,
+		Moved:      []string{"Old name -> New name"},
+		Deleted:    []string{"Spam", "Vanished (no longer listed)"},
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+	var titles []string
+	for _, page := range saved.Pages {
+		titles = append(titles, page.Title)
+	}
+	// Pages of namespaces that aren't exported this time are kept for later
+	if !reflect.DeepEqual(titles, []string{"Unchanged", "New name", "Template:Box"}) {
+		t.Errorf("Wrong manifest: %+v", saved)
+	}
+}
+
+func TestArchiveRemovedArticles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var saved manifest
+	mockClient, mockFileSystem := expectRemovals(t, mockCtrl, &saved)
+	dirMode := os.FileMode(0755)
+	gomock.InOrder(
+		mockFileSystem.EXPECT().MkdirAll(filepath.Join("outputFolder", "Main"), dirMode).Return(nil),
+		mockFileSystem.EXPECT().Rename(filepath.Join("outputFolder", "Main", "Old_name.txt"), filepath.Join("outputFolder", "Main", "New_name.txt")).Return(nil),
+		mockFileSystem.EXPECT().MkdirAll(filepath.Join("outputFolder", "_deleted", "Main"), dirMode).Return(nil),
+		mockFileSystem.EXPECT().Rename(filepath.Join("outputFolder", "Main", "Spam.txt"), filepath.Join("outputFolder", "_deleted", "Main", "Spam.txt")).Return(nil),
+		mockFileSystem.EXPECT().MkdirAll(filepath.Join("outputFolder", "_deleted", "Main"), dirMode).Return(nil),
+		// Files that are already gone are fine
+		mockFileSystem.EXPECT().Rename(filepath.Join("outputFolder", "Main", "Vanished.txt"), filepath.Join("outputFolder", "_deleted", "Main", "Vanished.txt")).Return(os.ErrNotExist),
+	)
+
+	options := exportOptions{Encoder: filename.Percent{}, Site: testManifest.Site, Removed: removedArchive}
+	summary, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkRemovals(t, summary, saved)
+}
+
+func TestDeleteRemovedArticles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var saved manifest
+	mockClient, mockFileSystem := expectRemovals(t, mockCtrl, &saved)
+	mockFileSystem.EXPECT().MkdirAll(filepath.Join("outputFolder", "Main"), os.FileMode(0755)).Return(nil)
+	mockFileSystem.EXPECT().Rename(filepath.Join("outputFolder", "Main", "Old_name.txt"), filepath.Join("outputFolder", "Main", "New_name.txt")).Return(nil)
+	mockFileSystem.EXPECT().RemoveAll(filepath.Join("outputFolder", "Main", "Spam.txt")).Return(nil)
+	mockFileSystem.EXPECT().RemoveAll(filepath.Join("outputFolder", "Main", "Vanished.txt")).Return(nil)
+
+	options := exportOptions{Encoder: filename.Percent{}, Site: testManifest.Site, Removed: removedDelete}
+	summary, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkRemovals(t, summary, saved)
+}
+
+func TestKeepRemovedArticles(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var saved manifest
+	mockClient, mockFileSystem := expectRemovals(t, mockCtrl, &saved)
+
+	options := exportOptions{Encoder: filename.Percent{}, Site: testManifest.Site, Removed: removedKeep}
+	summary, err := export(context.Background(), mockClient, "outputFolder", options, mockFileSystem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkRemovals(t, summary, saved)
+}
+
+func TestRemovedSidecars(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockFileSystem := newMockFileSystem(mockCtrl)
+	mockFileSystem.EXPECT().RemoveAll(filepath.Join("outputFolder", "Main", "Spam.txt")).Return(nil)
+	mockFileSystem.EXPECT().RemoveAll(filepath.Join("outputFolder", "Main", "Spam.json")).Return(os.ErrNotExist)
+	mockFileSystem.EXPECT().RemoveAll(filepath.Join("outputFolder", "Main", "Spam.history")).Return(nil)
+	if err := removeArticleFiles(mockFileSystem, "outputFolder", filepath.Join("Main", "Spam.txt"), &manifest{Metadata: metadataJSON, History: historyFiles}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestExportSummary(t *testing.T) {
+	summary := exportSummary{Downloaded: 2, Unchanged: 5, Moved: []string{"A -> B"}, Deleted: []string{"C"}}
+	expected := "Downloaded 2 articles, 5 unchanged\nMoved: A -> B\nDeleted: C"
+	if summary.String() != expected {
+		t.Errorf("Wrong summary: %s", summary)
+	}
+	if err := checkRemovedPolicy("shred"); err == nil {
+		t.Errorf("Should have rejected an unknown policy")
+	}
+}
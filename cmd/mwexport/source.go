@@ -0,0 +1,369 @@
+package main
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+	"github.com/stevearm/mediawiki-export/xmldump"
+)
+
+// Where articles are exported from: a wiki through its api (any mediawiki.Client), or an XML dump (see dumpSource)
+type source interface {
+	ListNamespacesContext(ctx context.Context) ([]mediawiki.Namespace, error)
+	GetSiteInfoContext(ctx context.Context) (mediawiki.SiteInfo, error)
+	ListTitlesContext(ctx context.Context, namespaces []int) ([]mediawiki.Title, error)
+	GetPagesContext(ctx context.Context, titles []string, followRedirects bool) ([]mediawiki.Page, error)
+	GetRedirectsContext(ctx context.Context, titles []string) (map[string]string, error)
+	RecentChangesContext(ctx context.Context, since time.Time, namespaces []int) ([]mediawiki.Change, error)
+	LogEventsContext(ctx context.Context, logType string, since time.Time) ([]mediawiki.LogEvent, error)
+	GetHistoryContext(ctx context.Context, title string) ([]mediawiki.Revision, error)
+	GetHistoryAfterContext(ctx context.Context, title string, revisionID int) ([]mediawiki.Revision, error)
+	ListFilesContext(ctx context.Context) ([]mediawiki.File, error)
+	DownloadFileContext(ctx context.Context, file mediawiki.File, w io.Writer) error
+}
+
+// How many articles to export from a dump at once. Dumps with every revision hold them all in memory for a batch
+const dumpBatchSize = 50
+
+// The canonical names of the namespaces built into MediaWiki, which dumps only give the local names of
+var canonicalNamespaces = map[int]string{
+	-2: "Media",
+	-1: "Special",
+	1:  "Talk",
+	2:  "User",
+	3:  "User talk",
+	4:  "Project",
+	5:  "Project talk",
+	6:  "File",
+	7:  "File talk",
+	8:  "MediaWiki",
+	9:  "MediaWiki talk",
+	10: "Template",
+	11: "Template talk",
+	12: "Help",
+	13: "Help talk",
+	14: "Category",
+	15: "Category talk",
+}
+
+// Reads a MediaWiki XML dump, such as pages-articles.xml.bz2, in place of a wiki. Lookups read on from where the last
+// one stopped, and only go back to the start of the dump once they reach its end
+type dumpSource struct {
+	path   string
+	info   xmldump.SiteInfo
+	lock   sync.Mutex
+	reader *xmldump.Reader // Where the last batch was found
+	file   io.Closer
+	recent map[string]xmldump.Page // The last batch, so its history can be asked for after its pages
+}
+
+func openDump(path string) (*dumpSource, error) {
+	reader, file, err := readDump(path)
+	if err != nil {
+		return nil, err
+	}
+	return &dumpSource{path: path, info: reader.SiteInfo(), reader: reader, file: file}, nil
+}
+
+// Open a dump for reading from the start, decompressing it if its filename ends in .gz or .bz2
+func readDump(path string) (*xmldump.Reader, io.Closer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var r io.Reader = file
+	switch dumpCompression(path) {
+	case compressionGzip:
+		if r, err = gzip.NewReader(file); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+	case compressionBzip2:
+		r = bzip2.NewReader(file)
+	}
+	reader, err := xmldump.NewReader(r)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return reader, file, nil
+}
+
+func (s *dumpSource) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Close()
+}
+
+// Identifies the wiki the dump came from, for the manifest
+func (s *dumpSource) Site() string {
+	if s.info.Base != "" {
+		return s.info.Base
+	}
+	path, err := filepath.Abs(s.path)
+	if err != nil {
+		return s.path
+	}
+	return path
+}
+
+// The namespaces listed in the dump. Like a default MediaWiki install, only the main namespace holds content
+func (s *dumpSource) ListNamespacesContext(ctx context.Context) ([]mediawiki.Namespace, error) {
+	var namespaces []mediawiki.Namespace
+	for _, namespace := range s.info.Namespaces {
+		namespaces = append(namespaces, mediawiki.Namespace{
+			ID:        namespace.Key,
+			Name:      namespace.Name,
+			Canonical: canonicalNamespaces[namespace.Key],
+			Content:   namespace.Key == 0,
+			Case:      namespace.Case,
+		})
+	}
+	return namespaces, nil
+}
+
+func (s *dumpSource) GetSiteInfoContext(ctx context.Context) (mediawiki.SiteInfo, error) {
+	return mediawiki.SiteInfo{
+		Name:      s.info.SiteName,
+		DBName:    s.info.DBName,
+		Base:      s.info.Base,
+		Generator: s.info.Generator,
+		Case:      s.info.Case,
+	}, nil
+}
+
+// List the pages of the namespaces in the order they appear in the dump, reading it from start to end
+func (s *dumpSource) ListTitlesContext(ctx context.Context, namespaces []int) ([]mediawiki.Title, error) {
+	selected := make(map[int]struct{})
+	for _, namespace := range namespaces {
+		selected[namespace] = struct{}{}
+	}
+	glog.Infof("Listing the articles of %s", s.path)
+	reader, file, err := readDump(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var titles []mediawiki.Title
+	for {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		page, err := reader.Next()
+		if err == io.EOF {
+			return titles, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, found := selected[page.Namespace]; found {
+			titles = append(titles, mediawiki.Title{Namespace: page.Namespace, Title: page.Title, PageID: page.ID})
+		}
+	}
+}
+
+// The latest revision of each page in the dump. Pages the dump doesn't hold are flagged as missing. If
+// followRedirects is set, redirect pages are replaced by the pages they point to
+func (s *dumpSource) GetPagesContext(ctx context.Context, titles []string, followRedirects bool) ([]mediawiki.Page, error) {
+	found, err := s.find(ctx, titles)
+	if err != nil {
+		return nil, err
+	}
+	redirects := make(map[string]string)
+	if followRedirects {
+		var targets []string
+		for _, page := range found {
+			if page.Redirect != "" {
+				redirects[page.Title] = page.Redirect
+				targets = append(targets, page.Redirect)
+			}
+		}
+		if len(targets) > 0 {
+			followed, err := s.find(ctx, targets)
+			if err != nil {
+				return nil, err
+			}
+			for title, page := range followed {
+				found[title] = page
+			}
+		}
+	}
+	pages := make([]mediawiki.Page, len(titles))
+	for i, title := range titles {
+		target, redirected := redirects[title]
+		if !redirected {
+			target = title
+		}
+		page, ok := found[target]
+		if !ok || len(page.Revisions) == 0 {
+			pages[i] = mediawiki.Page{Requested: title, Title: target, RedirectedTo: redirects[title], Missing: true}
+			continue
+		}
+		latest := page.Revisions[len(page.Revisions)-1]
+		pages[i] = mediawiki.Page{
+			Requested:    title,
+			Title:        page.Title,
+			Namespace:    page.Namespace,
+			PageID:       page.ID,
+			RevisionID:   latest.ID,
+			User:         dumpUser(latest.Contributor),
+			UserID:       latest.Contributor.ID,
+			Timestamp:    latest.Timestamp,
+			ContentModel: latest.Model,
+			SHA1:         latest.SHA1,
+			Content:      latest.Text,
+			RedirectedTo: redirects[title],
+		}
+	}
+	return pages, nil
+}
+
+// The title each redirect among titles points to, as the dump records it
+func (s *dumpSource) GetRedirectsContext(ctx context.Context, titles []string) (map[string]string, error) {
+	found, err := s.find(ctx, titles)
+	if err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string)
+	for title, page := range found {
+		if page.Redirect != "" {
+			targets[title] = page.Redirect
+		}
+	}
+	return targets, nil
+}
+
+// Every revision of a page the dump holds, which is only the latest for pages-articles dumps
+func (s *dumpSource) GetHistoryContext(ctx context.Context, title string) ([]mediawiki.Revision, error) {
+	return s.GetHistoryAfterContext(ctx, title, 0)
+}
+
+func (s *dumpSource) GetHistoryAfterContext(ctx context.Context, title string, revisionID int) ([]mediawiki.Revision, error) {
+	found, err := s.find(ctx, []string{title})
+	if err != nil {
+		return nil, err
+	}
+	page, ok := found[title]
+	if !ok {
+		return nil, fmt.Errorf("Article not found: %s", title)
+	}
+	var revisions []mediawiki.Revision
+	for _, revision := range page.Revisions {
+		if revision.ID <= revisionID {
+			continue
+		}
+		revisions = append(revisions, mediawiki.Revision{
+			ID:           revision.ID,
+			ParentID:     revision.ParentID,
+			User:         dumpUser(revision.Contributor),
+			UserID:       revision.Contributor.ID,
+			Comment:      revision.Comment,
+			Minor:        revision.Minor,
+			Timestamp:    revision.Timestamp,
+			Size:         len(revision.Text),
+			SHA1:         revision.SHA1,
+			ContentModel: revision.Model,
+			Content:      revision.Text,
+		})
+	}
+	return revisions, nil
+}
+
+func (s *dumpSource) RecentChangesContext(ctx context.Context, since time.Time, namespaces []int) ([]mediawiki.Change, error) {
+	return nil, errors.New("XML dumps have no recent changes, export everything from them instead")
+}
+
+// Dumps have no logs, so articles that are no longer in the dump are never known to be deleted or moved
+func (s *dumpSource) LogEventsContext(ctx context.Context, logType string, since time.Time) ([]mediawiki.LogEvent, error) {
+	return nil, nil
+}
+
+func (s *dumpSource) ListFilesContext(ctx context.Context) ([]mediawiki.File, error) {
+	return nil, errors.New("XML dumps hold no uploaded files")
+}
+
+func (s *dumpSource) DownloadFileContext(ctx context.Context, file mediawiki.File, w io.Writer) error {
+	return errors.New("XML dumps hold no uploaded files")
+}
+
+// Read on through the dump until every title is found, starting another pass if the end is reached first. Unless they
+// were all in the last batch, what was found replaces it
+func (s *dumpSource) find(ctx context.Context, titles []string) (map[string]xmldump.Page, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	wanted := make(map[string]struct{})
+	found := make(map[string]xmldump.Page)
+	for _, title := range titles {
+		if page, ok := s.recent[title]; ok {
+			found[title] = page
+		} else {
+			wanted[title] = struct{}{}
+		}
+	}
+	if len(wanted) == 0 {
+		return found, nil
+	}
+	rewound := false
+	for len(wanted) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		page, err := s.reader.Next()
+		if err == io.EOF && !rewound {
+			glog.V(1).Infof("Reading %s again from the start", s.path)
+			reader, file, err := readDump(s.path)
+			if err != nil {
+				return nil, err
+			}
+			s.file.Close()
+			s.reader, s.file = reader, file
+			rewound = true
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := wanted[page.Title]; ok {
+			found[page.Title] = page
+			delete(wanted, page.Title)
+		}
+	}
+	s.recent = found
+	return found, nil
+}
+
+// Revisions of anonymous users are credited to their IP address, as the api does
+func dumpUser(contributor xmldump.Contributor) string {
+	if contributor.Username != "" {
+		return contributor.Username
+	}
+	return contributor.IP
+}
+
+// Whether the url given on the command line is an XML dump rather than a wiki, going by its extension alone so that
+// a mistyped path is reported as missing
+func isDumpFile(location string) bool {
+	if strings.Contains(location, "://") {
+		return false
+	}
+	for _, extension := range []string{".xml", ".xml.gz", ".xml.bz2"} {
+		if strings.HasSuffix(location, extension) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stevearm/mediawiki-export/filename"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+const testDumpFile = "../../xmldump/testdata/pages-articles.xml"
+
+func openTestDump(t *testing.T) *dumpSource {
+	dump, err := openDump(testDumpFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return dump
+}
+
+func TestExportFromDump(t *testing.T) {
+	dump := openTestDump(t)
+	defer dump.Close()
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	options := exportOptions{Encoder: filename.Scrubber{}, Namespaces: "0,Recipe", Metadata: metadataFrontMatter, Site: dump.Site(), Full: true, BatchSize: 2, History: historyJSONL}
+	summary, err := export(context.Background(), dump, dir, options, localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.Downloaded != 3 {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+	expected := map[string]string{
+		"Main/Main_Page.txt":           "revid: 4\n",
+		"Main/Main_Page.history.jsonl": `"user":"192.0.2.1"`,
+		"Main/Home.txt":                "#REDIRECT [[Main Page]]",
+		"Recipe/Cake.txt":              "Mix flour & eggs, bake at <b>180</b>.",
+	}
+	for path, content := range expected {
+		written, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Errorf("Missing %s: %v", path, err)
+		} else if !strings.Contains(string(written), content) {
+			t.Errorf("Wrong %s:\n%s", path, written)
+		}
+	}
+}
+
+func TestDumpNamespaces(t *testing.T) {
+	dump := openTestDump(t)
+	defer dump.Close()
+	namespaces, err := dump.ListNamespacesContext(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(namespaces) != 7 {
+		t.Fatalf("Wrong namespaces: %+v", namespaces)
+	}
+	// Project namespaces are named after the wiki, and extensions' namespaces have no canonical name to go by
+	expected := []mediawiki.Namespace{
+		{ID: 0, Content: true, Case: "first-letter"},
+		{ID: 1, Name: "Talk", Canonical: "Talk", Case: "first-letter"},
+		{ID: 4, Name: "Example Wiki", Canonical: "Project", Case: "first-letter"},
+		{ID: 10, Name: "Template", Canonical: "Template", Case: "first-letter"},
+		{ID: 100, Name: "Recipe", Case: "first-letter"},
+	}
+	if !reflect.DeepEqual(namespaces[2:], expected) {
+		t.Errorf("Wrong namespaces: %+v", namespaces[2:])
+	}
+}
+
+func TestDumpPagesOutOfOrder(t *testing.T) {
+	dump := openTestDump(t)
+	defer dump.Close()
+	ctx := context.Background()
+	pages, err := dump.GetPagesContext(ctx, []string{"Recipe:Cake", "Nowhere"}, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pages[0].PageID != 3 || pages[0].User != "Carol Baker" || pages[0].SHA1 != "b66afd940bf4b9bcc17aca963750c8c72028549a" || !pages[1].Missing {
+		t.Errorf("Wrong pages: %+v", pages)
+	}
+	// Pages from earlier in the dump are found by reading it again
+	pages, err = dump.GetPagesContext(ctx, []string{"Main Page"}, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pages[0].RevisionID != 4 || pages[0].Content != "Welcome to the wiki.\n\nSee [[Recipe:Cake]]." {
+		t.Errorf("Wrong page: %+v", pages[0])
+	}
+	revisions, err := dump.GetHistoryAfterContext(ctx, "Main Page", 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(revisions) != 1 || revisions[0].ID != 4 || !revisions[0].Minor || revisions[0].Size != 42 {
+		t.Errorf("Wrong revisions: %+v", revisions)
+	}
+	if _, err = dump.GetHistoryContext(ctx, "Nowhere"); err == nil || err.Error() != "Article not found: Nowhere" {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
+
+func TestDumpRedirects(t *testing.T) {
+	dump := openTestDump(t)
+	defer dump.Close()
+	pages, err := dump.GetPagesContext(context.Background(), []string{"Home", "Recipe:Cake"}, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pages[0].Requested != "Home" || pages[0].Title != "Main Page" || pages[0].RedirectedTo != "Main Page" || pages[0].RevisionID != 4 {
+		t.Errorf("Redirect not followed: %+v", pages[0])
+	}
+	if pages[1].Title != "Recipe:Cake" || pages[1].RedirectedTo != "" {
+		t.Errorf("Wrong page: %+v", pages[1])
+	}
+	pages, err = dump.GetPagesContext(context.Background(), []string{"Home"}, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pages[0].Title != "Home" || pages[0].Content != "#REDIRECT [[Main Page]]" {
+		t.Errorf("Redirect followed: %+v", pages[0])
+	}
+}
+
+func TestMissingDumpFile(t *testing.T) {
+	flags := connectionFlags{}
+	if _, err := flags.open("missing-pages-articles.xml"); err == nil || err.Error() != "Dump file not found: missing-pages-articles.xml" {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
+
+func TestCompressedDump(t *testing.T) {
+	content, err := ioutil.ReadFile(testDumpFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dumpPath := filepath.Join(dir, "pages-articles.xml.gz")
+	file, err := os.Create(dumpPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	compressor := gzip.NewWriter(file)
+	compressor.Write(content)
+	compressor.Close()
+	file.Close()
+
+	if !isDumpFile(dumpPath) || isDumpFile("wiki.example.org") || isDumpFile("https://wiki.example.org/sitemap.xml") {
+		t.Errorf("Dump files not told apart from wikis")
+	}
+	dump, err := openDump(dumpPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer dump.Close()
+	titles, err := dump.ListTitlesContext(context.Background(), []int{0})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []mediawiki.Title{{Namespace: 0, Title: "Main Page", PageID: 1}, {Namespace: 0, Title: "Home", PageID: 2}}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("Wrong titles: %+v", titles)
+	}
+}
+
+func TestNotADump(t *testing.T) {
+	file, err := ioutil.TempFile("", "mwexport*.xml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("<html><body>Not found</body></html>")
+	file.Close()
+	if _, err = openDump(file.Name()); err == nil || err.Error() != "Not a MediaWiki XML dump" {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
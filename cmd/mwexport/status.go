@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+// How an export directory compares with the wiki it came from
+type exportStatus struct {
+	New       []string // Articles that were never exported
+	Changed   []string // Articles saved on the wiki since they were exported
+	Deleted   []string // Exported articles that are no longer on the wiki, including moved ones
+	Unchanged int
+}
+
+func (s exportStatus) String() string {
+	lines := []string{fmt.Sprintf("%d new, %d changed, %d deleted, %d unchanged", len(s.New), len(s.Changed), len(s.Deleted), s.Unchanged)}
+	for _, title := range s.New {
+		lines = append(lines, "New: "+title)
+	}
+	for _, title := range s.Changed {
+		lines = append(lines, "Changed: "+title)
+	}
+	for _, title := range s.Deleted {
+		lines = append(lines, "Deleted: "+title)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Compare the articles of the selected namespaces in an export directory with the wiki, by the latest revision of
+// each. Nothing is written, so the next export still downloads whatever changed
+func compareExport(ctx context.Context, wiki source, exportDir string, options exportOptions, fs fileSystem) (exportStatus, error) {
+	var status exportStatus
+	exported, err := readManifest(fs, exportDir)
+	if err != nil {
+		return status, err
+	}
+	switch {
+	case exported == nil:
+		return status, fmt.Errorf("No export found in %s", exportDir)
+	case exported.Site != options.Site:
+		// Revision IDs mean nothing on another wiki
+		return status, fmt.Errorf("%s was exported from %s", exportDir, exported.Site)
+	}
+	titles, namespacesByID, _, err := listArticles(ctx, wiki, options.Namespaces)
+	if err != nil {
+		return status, err
+	}
+	known := exported.byTitle()
+	listed := make(map[string]struct{})
+	var compared []string
+	for _, title := range titles {
+		listed[title.Title] = struct{}{}
+		if _, found := known[title.Title]; found {
+			compared = append(compared, title.Title)
+		} else {
+			status.New = append(status.New, title.Title)
+		}
+	}
+	for _, page := range exported.Pages {
+		_, selected := namespacesByID[page.Namespace]
+		if _, found := listed[page.Title]; selected && !found {
+			status.Deleted = append(status.Deleted, page.Title)
+		}
+	}
+
+	batchSize := options.BatchSize
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+	var batches [][]string
+	for start := 0; start < len(compared); start += batchSize {
+		end := start + batchSize
+		if end > len(compared) {
+			end = len(compared)
+		}
+		batches = append(batches, compared[start:end])
+	}
+	glog.Infof("Comparing %d articles with the wiki", len(compared))
+	results := make([][]mediawiki.Page, len(batches))
+	err = runWorkers(ctx, options.Concurrency, len(batches), func(index int) error {
+		var err error
+		results[index], err = wiki.GetPagesContext(ctx, batches[index], false)
+		return err
+	})
+	if err != nil {
+		return status, err
+	}
+	for _, pages := range results {
+		for _, page := range pages {
+			switch {
+			case page.Missing:
+				// Deleted since it was listed
+				status.Deleted = append(status.Deleted, page.Requested)
+			case page.RevisionID != known[page.Requested].RevisionID:
+				status.Changed = append(status.Changed, page.Requested)
+			default:
+				status.Unchanged++
+			}
+		}
+	}
+	return status, nil
+}
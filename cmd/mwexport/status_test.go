@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+)
+
+func TestCompareExport(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir := writeTestExport(t)
+	defer os.RemoveAll(dir)
+
+	mockClient := newMockListing(mockCtrl, []mediawiki.Title{
+		{Title: "Home"},
+		{Title: "About"},
+		{Title: "Recipe:Cake", Namespace: 100},
+		{Title: "Contact"},
+	})
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Home", "About", "Recipe:Cake"}, false).Return([]mediawiki.Page{
+		{Requested: "Home", Title: "Home"},
+		{Requested: "About", Title: "About", RevisionID: 5},
+		{Requested: "Recipe:Cake", Title: "Recipe:Cake", Missing: true},
+	}, nil)
+	options := exportOptions{Site: "http://old.example.org/api.php", Concurrency: 2}
+	status, err := compareExport(context.Background(), mockClient, dir, options, localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := exportStatus{
+		New:       []string{"Contact"},
+		Changed:   []string{"About"},
+		Deleted:   []string{"Gone", "Recipe:Cake"},
+		Unchanged: 1,
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("Wrong status: %+v", status)
+	}
+	if status.String() != "1 new, 1 changed, 2 deleted, 1 unchanged\nNew: Contact\nChanged: About\nDeleted: Gone\nDeleted: Recipe:Cake" {
+		t.Errorf("Wrong status text: %q", status.String())
+	}
+}
+
+func TestCompareExportOfAnotherWiki(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir := writeTestExport(t)
+	defer os.RemoveAll(dir)
+
+	mockClient := mediawiki.NewMockClient(mockCtrl)
+	options := exportOptions{Site: "http://new.example.org/api.php"}
+	if _, err := compareExport(context.Background(), mockClient, dir, options, localFileSystem{}); err == nil {
+		t.Errorf("Expected an error comparing with another wiki")
+	}
+}
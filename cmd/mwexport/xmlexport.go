@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+	"github.com/stevearm/mediawiki-export/xmldump"
+)
+
+// How a dump is compressed, picked from the extension of its filename
+const (
+	compressionNone  = ""
+	compressionGzip  = "gzip"
+	compressionBzip2 = "bzip2" // Using the bzip2 command, as Go can only decompress it
+)
+
+func dumpCompression(dumpPath string) string {
+	switch {
+	case strings.HasSuffix(dumpPath, ".gz"):
+		return compressionGzip
+	case strings.HasSuffix(dumpPath, ".bz2"):
+		return compressionBzip2
+	}
+	return compressionNone
+}
+
+// Make sure a dump can be compressed as its filename asks before any articles are fetched
+func checkDumpCompression(dumpPath string) error {
+	if dumpCompression(dumpPath) != compressionBzip2 {
+		return nil
+	}
+	if _, err := exec.LookPath("bzip2"); err != nil {
+		return fmt.Errorf("Writing %s needs the bzip2 command, which was not found. Name the dump .gz instead", dumpPath)
+	}
+	return nil
+}
+
+// Export the wiki as a MediaWiki XML dump into dumpPath, which is compressed if it ends in .gz or .bz2
+func exportXML(ctx context.Context, wiki source, dumpPath string, options exportOptions, fs fileSystem) (exportSummary, error) {
+	var summary exportSummary
+	titles, _, _, err := listArticles(ctx, wiki, options.Namespaces)
+	if err != nil {
+		return summary, err
+	}
+	info, err := wiki.GetSiteInfoContext(ctx)
+	if err != nil {
+		return summary, err
+	}
+	// Every namespace goes in the site information, not just the exported ones
+	namespaces, err := wiki.ListNamespacesContext(ctx)
+	if err != nil {
+		return summary, err
+	}
+	siteInfo := xmldump.SiteInfo{
+		SiteName:  info.Name,
+		DBName:    info.DBName,
+		Base:      info.Base,
+		Generator: info.Generator,
+		Case:      info.Case,
+	}
+	for _, namespace := range namespaces {
+		// Older wikis don't say how each namespace is cased, which then follows the site
+		caseRule := namespace.Case
+		if caseRule == "" {
+			caseRule = info.Case
+		}
+		siteInfo.Namespaces = append(siteInfo.Namespaces, xmldump.Namespace{Key: namespace.ID, Case: caseRule, Name: namespace.Name})
+	}
+	history := options.History != historyNone && options.History != ""
+	err = fs.WriteStream(dumpPath, 0644, func(w io.Writer) error {
+		return compressDump(ctx, w, dumpCompression(dumpPath), func(w io.Writer) error {
+			return writeDump(ctx, wiki, w, siteInfo, titles, history, options, &summary)
+		})
+	})
+	if err != nil {
+		return summary, err
+	}
+	summary.Downloaded = len(titles)
+	return summary, nil
+}
+
+// Run write on a writer that compresses into w
+func compressDump(ctx context.Context, w io.Writer, compression string, write func(w io.Writer) error) error {
+	switch compression {
+	case compressionGzip:
+		compressor := gzip.NewWriter(w)
+		if err := write(compressor); err != nil {
+			return err
+		}
+		return compressor.Close()
+	case compressionBzip2:
+		var stderr bytes.Buffer
+		command := exec.CommandContext(ctx, "bzip2", "-c")
+		command.Stdout = w
+		command.Stderr = &stderr
+		stdin, err := command.StdinPipe()
+		if err != nil {
+			return err
+		}
+		if err = command.Start(); err != nil {
+			return fmt.Errorf("Cannot run bzip2 to compress the dump: %v", err)
+		}
+		err = write(stdin)
+		if closeErr := stdin.Close(); err == nil {
+			err = closeErr
+		}
+		if waitErr := command.Wait(); err == nil && waitErr != nil {
+			err = fmt.Errorf("bzip2 failed: %v %s", waitErr, strings.TrimSpace(stderr.String()))
+		}
+		return err
+	}
+	return write(w)
+}
+
+// Fetch the articles one window of batches at a time, and write them into the dump in the order they are listed
+func writeDump(ctx context.Context, wiki source, w io.Writer, siteInfo xmldump.SiteInfo, titles []mediawiki.Title, history bool, options exportOptions, summary *exportSummary) error {
+	dump, err := xmldump.NewWriter(w, siteInfo)
+	if err != nil {
+		return err
+	}
+	batchSize := options.BatchSize
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+	if history {
+		batchSize = 1
+	}
+	var batches [][]mediawiki.Title
+	for start := 0; start < len(titles); start += batchSize {
+		end := start + batchSize
+		if end > len(titles) {
+			end = len(titles)
+		}
+		batches = append(batches, titles[start:end])
+	}
+	window := options.Concurrency
+	if window < 1 {
+		window = 1
+	}
+	glog.Infof("Dumping %d articles", len(titles))
+	for start := 0; start < len(batches); start += window {
+		end := start + window
+		if end > len(batches) {
+			end = len(batches)
+		}
+		results := make([][]xmldump.Page, end-start)
+		err = runWorkers(ctx, options.Concurrency, len(results), func(index int) error {
+			var err error
+			results[index], err = fetchDumpPages(ctx, wiki, batches[start+index], history)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, pages := range results {
+			for _, page := range pages {
+				if err = dump.WritePage(page); err != nil {
+					return err
+				}
+				summary.Revisions += len(page.Revisions)
+			}
+		}
+	}
+	return dump.Close()
+}
+
+// Fetch a batch of articles for the dump, either with every revision or just the latest
+func fetchDumpPages(ctx context.Context, wiki source, titles []mediawiki.Title, history bool) ([]xmldump.Page, error) {
+	names := make([]string, len(titles))
+	for i, title := range titles {
+		names[i] = title.Title
+	}
+	// Redirects aren't followed for the content, so where they point is asked for separately
+	redirects, err := wiki.GetRedirectsContext(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	var pages []xmldump.Page
+	if history {
+		for _, title := range titles {
+			revisions, err := wiki.GetHistoryContext(ctx, title.Title)
+			if err != nil {
+				return nil, err
+			}
+			page := xmldump.Page{Title: title.Title, Namespace: title.Namespace, ID: title.PageID, Redirect: redirects[title.Title]}
+			for _, revision := range revisions {
+				page.Revisions = append(page.Revisions, xmldump.Revision{
+					ID:          revision.ID,
+					ParentID:    revision.ParentID,
+					Timestamp:   revision.Timestamp,
+					Contributor: dumpContributor(revision.User, revision.UserID),
+					Minor:       revision.Minor,
+					Comment:     revision.Comment,
+					Model:       revision.ContentModel,
+					Text:        revision.Content,
+					SHA1:        revision.SHA1,
+				})
+			}
+			pages = append(pages, page)
+		}
+		return pages, nil
+	}
+	latest, err := wiki.GetPagesContext(ctx, names, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range latest {
+		switch {
+		case page.Invalid:
+			return nil, fmt.Errorf("Invalid title %s: %s", page.Requested, page.InvalidReason)
+		case page.Missing:
+			return nil, fmt.Errorf("Article not found: %s", page.Requested)
+		}
+		pages = append(pages, xmldump.Page{
+			Title:     page.Title,
+			Namespace: page.Namespace,
+			ID:        page.PageID,
+			Redirect:  redirects[page.Requested],
+			Revisions: []xmldump.Revision{{
+				ID:          page.RevisionID,
+				Timestamp:   page.Timestamp,
+				Contributor: dumpContributor(page.User, page.UserID),
+				Model:       page.ContentModel,
+				Text:        page.Content,
+				SHA1:        page.SHA1,
+			}},
+		})
+	}
+	return pages, nil
+}
+
+// Anonymous edits are credited to an IP address, and hidden users come back empty
+func dumpContributor(user string, userID int) xmldump.Contributor {
+	switch {
+	case user == "":
+		return xmldump.Contributor{}
+	case net.ParseIP(user) != nil:
+		return xmldump.Contributor{IP: user}
+	}
+	return xmldump.Contributor{Username: user, ID: userID}
+}
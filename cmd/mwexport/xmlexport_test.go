@@ -0,0 +1,218 @@
+package main
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stevearm/mediawiki-export/mediawiki"
+	"github.com/stevearm/mediawiki-export/xmldump"
+)
+
+func expectDumpListing(mockCtrl *gomock.Controller) *mediawiki.MockClient {
+	mockClient := newMockListing(mockCtrl, []mediawiki.Title{
+		{Namespace: 0, Title: "Home", PageID: 1},
+		{Namespace: 100, Title: "Recipe:Cake", PageID: 2},
+	})
+	mockClient.EXPECT().ListNamespacesContext(gomock.Any()).Return(testNamespaces, nil)
+	mockClient.EXPECT().GetSiteInfoContext(gomock.Any()).Return(mediawiki.SiteInfo{Name: "Example Wiki", DBName: "examplewiki", Case: "first-letter"}, nil)
+	return mockClient
+}
+
+func readGzippedDump(t *testing.T, dumpPath string) string {
+	file, err := os.Open(dumpPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Dump is not gzipped: %v", err)
+	}
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return string(content)
+}
+
+func TestExportXML(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mockClient := expectDumpListing(mockCtrl)
+	mockClient.EXPECT().GetRedirectsContext(gomock.Any(), []string{"Home", "Recipe:Cake"}).Return(map[string]string{"Home": "Main Page"}, nil)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Home", "Recipe:Cake"}, false).Return([]mediawiki.Page{
+		{Requested: "Home", Title: "Home", PageID: 1, RevisionID: 10, User: "Alice", UserID: 5, ContentModel: "wikitext", Content: "#REDIRECT [[Main Page]]"},
+		{Requested: "Recipe:Cake", Title: "Recipe:Cake", Namespace: 100, PageID: 2, RevisionID: 11, User: "192.0.2.1", ContentModel: "wikitext", Content: "Flour"},
+	}, nil)
+	dumpPath := filepath.Join(dir, "wiki.xml.gz")
+	summary, err := exportXML(context.Background(), mockClient, dumpPath, exportOptions{History: historyNone}, localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.Downloaded != 2 || summary.Revisions != 2 {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+	dump := readGzippedDump(t, dumpPath)
+	for _, expected := range []string{
+		"<sitename>Example Wiki</sitename>",
+		`<namespace key="100" case="first-letter">Recipe</namespace>`,
+		"<title>Home</title>",
+		`<redirect title="Main Page"></redirect>`,
+		"<id>5</id>",
+		"<ip>192.0.2.1</ip>",
+		`>Flour</text>`,
+		"</mediawiki>",
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("Dump is missing %s:\n%s", expected, dump)
+		}
+	}
+}
+
+func TestExportXMLHistory(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mockClient := expectDumpListing(mockCtrl)
+	mockClient.EXPECT().GetRedirectsContext(gomock.Any(), gomock.Any()).Return(map[string]string{}, nil).Times(2)
+	mockClient.EXPECT().GetHistoryContext(gomock.Any(), "Home").Return([]mediawiki.Revision{
+		testRevision(10, "Alice", 1, "Created page", "Welcome"),
+		testRevision(12, "", 3, "", "Welcome!"),
+	}, nil)
+	mockClient.EXPECT().GetHistoryContext(gomock.Any(), "Recipe:Cake").Return([]mediawiki.Revision{
+		testRevision(11, "Carol Baker", 2, "Flour", "Flour"),
+	}, nil)
+	dumpPath := filepath.Join(dir, "wiki.xml")
+	summary, err := exportXML(context.Background(), mockClient, dumpPath, exportOptions{History: historyFiles, Concurrency: 2}, localFileSystem{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.Downloaded != 2 || summary.Revisions != 3 {
+		t.Errorf("Wrong summary: %+v", summary)
+	}
+	content, err := ioutil.ReadFile(dumpPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dump := string(content)
+	// Pages stay in the order they were listed, with their revisions in order
+	order := []string{"<id>1</id>", "<id>10</id>", "<comment>Created page</comment>", `<contributor deleted="deleted"></contributor>`, "<id>2</id>", "<username>Carol Baker</username>"}
+	position := 0
+	for _, expected := range order {
+		index := strings.Index(dump[position:], expected)
+		if index < 0 {
+			t.Fatalf("Dump is missing %s after position %d:\n%s", expected, position, dump)
+		}
+		position += index + len(expected)
+	}
+}
+
+func TestExportXMLFailureLeavesNoDump(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mockClient := expectDumpListing(mockCtrl)
+	mockClient.EXPECT().GetRedirectsContext(gomock.Any(), []string{"Home", "Recipe:Cake"}).Return(map[string]string{}, nil)
+	mockClient.EXPECT().GetPagesContext(gomock.Any(), []string{"Home", "Recipe:Cake"}, false).Return([]mediawiki.Page{
+		{Title: "Home", RevisionID: 10, Content: "Welcome"},
+		{Requested: "Recipe:Cake", Missing: true},
+	}, nil)
+	dumpPath := filepath.Join(dir, "wiki.xml")
+	_, err = exportXML(context.Background(), mockClient, dumpPath, exportOptions{History: historyNone}, localFileSystem{})
+	if err == nil || err.Error() != "Article not found: Recipe:Cake" {
+		t.Errorf("Wrong error: %v", err)
+	}
+	if _, err = os.Stat(dumpPath); !os.IsNotExist(err) {
+		t.Errorf("Dump was written: %v", err)
+	}
+}
+
+func TestCompressDumpBzip2(t *testing.T) {
+	if _, err := exec.LookPath("bzip2"); err != nil {
+		t.Skip("bzip2 is not installed")
+	}
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dumpPath := filepath.Join(dir, "wiki.xml.bz2")
+	err = localFileSystem{}.WriteStream(dumpPath, 0644, func(w io.Writer) error {
+		return compressDump(context.Background(), w, dumpCompression(dumpPath), func(w io.Writer) error {
+			_, err := io.WriteString(w, "<mediawiki/>")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	file, err := os.Open(dumpPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(bzip2.NewReader(file))
+	if err != nil || string(content) != "<mediawiki/>" {
+		t.Errorf("Wrong dump %q: %v", content, err)
+	}
+}
+
+func TestCheckDumpCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mwexport")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir)
+	if err = checkDumpCompression("wiki.xml.gz"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	err = checkDumpCompression("wiki.xml.bz2")
+	if err == nil || err.Error() != "Writing wiki.xml.bz2 needs the bzip2 command, which was not found. Name the dump .gz instead" {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
+
+func TestDumpContributor(t *testing.T) {
+	for user, expected := range map[string]xmldump.Contributor{
+		"":            {},
+		"Alice":       {Username: "Alice", ID: 7},
+		"192.0.2.1":   {IP: "192.0.2.1"},
+		"2001:DB8::1": {IP: "2001:DB8::1"},
+	} {
+		userID := 0
+		if expected.Username != "" {
+			userID = 7
+		}
+		if actual := dumpContributor(user, userID); actual != expected {
+			t.Errorf("Wrong contributor for %q: %+v", user, actual)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package filename turns wiki titles into names that are safe to use on common filesystems, and back again
+package filename
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Longest filename in bytes that the encoders produce, which leaves room for the extensions an export adds within the
+// 255 bytes most filesystems allow. Longer names are cut short, and end with %% and a hash of the whole title
+const MaxLength = 200
+
+// Decoding a filename that was cut short to MaxLength. Its title has to be found elsewhere, such as in the manifest of
+// an export
+var ErrShortened = errors.New("Filename was shortened, so its title cannot be decoded")
+
+// Converts titles to filenames (without an extension) and back
+type Encoder interface {
+	// Get the filename for a title
+	Encode(title string) string
+	// Get the title a filename was encoded from
+	Decode(name string) (string, error)
+	// Get an alternative filename for a title that cannot clash with any other title's filename when compared
+	// case-insensitively. Returns "" if the scheme has no such alternative
+	EncodeCaseSafe(title string) string
+}
+
+// Get an encoder by name. Known schemes are "percent", "percent-ascii" and "scrub"
+func Get(scheme string) (Encoder, error) {
+	switch scheme {
+	case "percent":
+		return Percent{}, nil
+	case "percent-ascii":
+		return Percent{ASCIIOnly: true}, nil
+	case "scrub":
+		return Scrubber{}, nil
+	}
+	return nil, fmt.Errorf("Unknown filename scheme: %s", scheme)
+}
+
+// Percent is a reversible encoder. Spaces become underscores, and anything unsafe on Windows, macOS or Linux is
+// escaped as %XX. Unicode is kept as is unless ASCIIOnly is set
+type Percent struct {
+	ASCIIOnly bool
+}
+
+func (p Percent) Encode(title string) string {
+	return p.encode(title, false)
+}
+
+func (p Percent) EncodeCaseSafe(title string) string {
+	return p.encode(title, true)
+}
+
+func (p Percent) encode(title string, escapeUpper bool) string {
+	var buffer []byte
+	// Where each character ends, so a shortened name never splits one
+	var ends []int
+	for i, r := range title {
+		if i > 0 {
+			ends = append(ends, len(buffer))
+		}
+		switch {
+		case r == ' ':
+			buffer = append(buffer, '_')
+		case r < 0x20 || r == 0x7f || strings.ContainsRune(`"%*/:<>?\|_`, r):
+			buffer = escape(buffer, string(r))
+		case r == '.' && (i == 0 || i == len(title)-1):
+			// Leading dots hide files (or mean . and ..), and Windows drops trailing ones
+			buffer = escape(buffer, string(r))
+		case i == 0 && isReserved(title):
+			buffer = escape(buffer, string(r))
+		case r >= utf8.RuneSelf && p.ASCIIOnly:
+			buffer = escape(buffer, string(r))
+		case escapeUpper && unicode.IsUpper(r):
+			buffer = escape(buffer, string(r))
+		default:
+			buffer = append(buffer, string(r)...)
+		}
+	}
+	return shorten(string(buffer), ends, title)
+}
+
+func (Percent) Decode(name string) (string, error) {
+	if strings.Contains(name, "%%") {
+		return "", ErrShortened
+	}
+	buffer := make([]byte, 0, len(name))
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '_':
+			buffer = append(buffer, ' ')
+		case '%':
+			if i+2 >= len(name) {
+				return "", fmt.Errorf("Truncated escape in filename: %s", name)
+			}
+			high, highOk := unhex(name[i+1])
+			low, lowOk := unhex(name[i+2])
+			if !highOk || !lowOk {
+				return "", fmt.Errorf("Invalid escape in filename: %s", name)
+			}
+			buffer = append(buffer, high<<4|low)
+			i += 2
+		default:
+			buffer = append(buffer, name[i])
+		}
+	}
+	if !utf8.Valid(buffer) {
+		return "", fmt.Errorf("Filename does not decode to valid UTF-8: %s", name)
+	}
+	return string(buffer), nil
+}
+
+// Cut a name longer than MaxLength at the last character end that leaves room for a hash of the title. Neither encoder
+// produces %% otherwise, so the hash keeps shortened names apart from each other and from every other name
+func shorten(name string, ends []int, title string) string {
+	if len(name) <= MaxLength {
+		return name
+	}
+	hash := sha256.Sum256([]byte(title))
+	suffix := "%%" + hex.EncodeToString(hash[:8])
+	cut := 0
+	for _, end := range ends {
+		if end > MaxLength-len(suffix) {
+			break
+		}
+		cut = end
+	}
+	return name[:cut] + suffix
+}
+
+// Device names that Windows will not allow as the base of a filename, whatever the extension
+var reservedNames = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {}, "COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {}, "LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+func isReserved(title string) bool {
+	base := title
+	if dot := strings.IndexRune(base, '.'); dot >= 0 {
+		base = base[:dot]
+	}
+	_, found := reservedNames[strings.ToUpper(strings.TrimRight(base, " "))]
+	return found
+}
+
+func escape(buffer []byte, s string) []byte {
+	const hex = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		buffer = append(buffer, '%', hex[s[i]>>4], hex[s[i]&0xf])
+	}
+	return buffer
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// Scrubber is the original lossy scheme, which replaces everything other than ASCII letters, digits, dashes and
+// underscores with an underscore. It cannot be decoded
+type Scrubber struct{}
+
+var scrubRegex = regexp.MustCompile("[^A-Za-z0-9_-]")
+
+func (Scrubber) Encode(title string) string {
+	name := scrubRegex.ReplaceAllString(title, "_")
+	ends := make([]int, len(name))
+	for i := range ends {
+		ends[i] = i
+	}
+	return shorten(name, ends, title)
+}
+
+func (Scrubber) EncodeCaseSafe(title string) string {
+	return ""
+}
+
+func (Scrubber) Decode(name string) (string, error) {
+	return "", errors.New("Scrubbed filenames cannot be decoded")
+}
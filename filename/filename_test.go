@@ -0,0 +1,133 @@
+package filename
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPercentEncode(t *testing.T) {
+	encoder := Percent{}
+	assertEncode(t, encoder, "House", "House")
+	assertEncode(t, encoder, "Other House", "Other_House")
+	assertEncode(t, encoder, "Other_House", "Other%5FHouse")
+	assertEncode(t, encoder, "Other's House", "Other's_House")
+	assertEncode(t, encoder, "100% sure", "100%25_sure")
+	assertEncode(t, encoder, "AC/DC: Live?", "AC%2FDC%3A_Live%3F")
+	assertEncode(t, encoder, `<a|b>*"c\d"`, "%3Ca%7Cb%3E%2A%22c%5Cd%22")
+	assertEncode(t, encoder, "Zürich", "Zürich")
+	assertEncode(t, encoder, ".htaccess", "%2Ehtaccess")
+	assertEncode(t, encoder, "Et al.", "Et_al%2E")
+	assertEncode(t, encoder, "v1.2", "v1.2")
+	assertEncode(t, encoder, "Con", "%43on")
+	assertEncode(t, encoder, "LPT1.Manual", "%4CPT1.Manual")
+	assertEncode(t, encoder, "Console", "Console")
+	assertEncode(t, Percent{ASCIIOnly: true}, "Zürich", "Z%C3%BCrich")
+}
+
+func TestPercentEncodeCaseSafe(t *testing.T) {
+	encoder := Percent{}
+	assertEncodeCaseSafe(t, encoder, "NASA", "%4E%41%53%41")
+	assertEncodeCaseSafe(t, encoder, "Nasa", "%4Easa")
+	assertEncodeCaseSafe(t, encoder, "Émile Zola", "%C3%89mile_%5Aola")
+}
+
+func TestPercentRoundTrip(t *testing.T) {
+	for _, encoder := range []Percent{{}, {ASCIIOnly: true}} {
+		for _, title := range []string{
+			"House", "Other_House", "Other's House", "100% sure", "AC/DC: Live?", `<a|b>*"c\d"`, "Zürich",
+			".htaccess", "Et al.", "Con", "NUL", "日本語のページ", "Tab\there",
+		} {
+			assertDecode(t, encoder, encoder.Encode(title), title)
+			assertDecode(t, encoder, encoder.EncodeCaseSafe(title), title)
+		}
+	}
+}
+
+func TestPercentDecode(t *testing.T) {
+	encoder := Percent{}
+	assertDecode(t, encoder, "Lower%2fcase", "Lower/case")
+	for _, name := range []string{"Bad%", "Bad%4", "Bad%zz", "Bad%FF"} {
+		if title, err := encoder.Decode(name); err == nil {
+			t.Errorf("Decoding %v should have failed: %v", name, title)
+		}
+	}
+}
+
+func TestLongTitles(t *testing.T) {
+	// 255 characters, which percent-ascii makes 6 bytes each
+	long := strings.Repeat("ü", 254) + "x"
+	other := strings.Repeat("ü", 254) + "y"
+	for _, encoder := range []Encoder{Percent{}, Percent{ASCIIOnly: true}, Scrubber{}} {
+		name := encoder.Encode(long)
+		if len(name) > MaxLength || !utf8.ValidString(name) || !strings.Contains(name, "%%") {
+			t.Errorf("Wrong shortened name: %v", name)
+		}
+		if encoder.Encode(other) == name {
+			t.Errorf("Shortened names should differ: %v", name)
+		}
+		if _, err := encoder.Decode(name); err == nil {
+			t.Errorf("Decoding a shortened name should fail")
+		}
+	}
+	// Escapes are never split
+	if name := (Percent{ASCIIOnly: true}).Encode(long); strings.Index(name, "%%") != 180 {
+		t.Errorf("Wrong cut: %v", name)
+	}
+	if name := (Percent{}).EncodeCaseSafe(strings.Repeat("A", 255)); len(name) > MaxLength || !strings.HasPrefix(name, "%41%41") {
+		t.Errorf("Wrong shortened case safe name: %v", name)
+	}
+	if _, err := (Percent{}).Decode(Percent{}.Encode(long)); err != ErrShortened {
+		t.Errorf("Expected ErrShortened: %v", err)
+	}
+}
+
+func TestScrubTitle(t *testing.T) {
+	scrubber := Scrubber{}
+	assertEncode(t, scrubber, "House", "House")
+	assertEncode(t, scrubber, "OtherHouse", "OtherHouse")
+	assertEncode(t, scrubber, "Other House", "Other_House")
+	assertEncode(t, scrubber, "Other_House", "Other_House")
+	assertEncode(t, scrubber, "Other's House", "Other_s_House")
+	if scrubber.EncodeCaseSafe("House") != "" {
+		t.Errorf("Scrubbed names have no case safe alternative")
+	}
+	if _, err := scrubber.Decode("House"); err == nil {
+		t.Errorf("Scrubbed names should not decode")
+	}
+}
+
+func TestGet(t *testing.T) {
+	for _, scheme := range []string{"percent", "percent-ascii", "scrub"} {
+		if _, err := Get(scheme); err != nil {
+			t.Errorf("Unexpected error for %v: %v", scheme, err)
+		}
+	}
+	if _, err := Get("base64"); err == nil {
+		t.Errorf("Should have failed on an unknown scheme")
+	}
+}
+
+func assertEncode(t *testing.T, encoder Encoder, source, expected string) {
+	actual := encoder.Encode(source)
+	if actual != expected {
+		t.Errorf("Encode %v to %v failed: %v", source, expected, actual)
+	}
+}
+
+func assertEncodeCaseSafe(t *testing.T, encoder Encoder, source, expected string) {
+	actual := encoder.EncodeCaseSafe(source)
+	if actual != expected {
+		t.Errorf("Case safe encode %v to %v failed: %v", source, expected, actual)
+	}
+}
+
+func assertDecode(t *testing.T, encoder Encoder, source, expected string) {
+	actual, err := encoder.Decode(source)
+	if err != nil {
+		t.Errorf("Unexpected error decoding %v: %v", source, err)
+	}
+	if actual != expected {
+		t.Errorf("Decode %v to %v failed: %v", source, expected, actual)
+	}
+}
@@ -0,0 +1,80 @@
+package gitrepo
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Who made a commit, and when
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+// Git can't store these in names or emails
+var signatureReplacer = strings.NewReplacer("<", "", ">", "", "\n", " ")
+
+func (s Signature) String() string {
+	_, offset := s.When.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("%s <%s> %d %c%02d%02d", signatureReplacer.Replace(s.Name), signatureReplacer.Replace(s.Email),
+		s.When.Unix(), sign, offset/3600, offset/60%60)
+}
+
+// A commit as read back from the repository
+type Commit struct {
+	Tree    Hash
+	Parent  Hash // Zero for the first commit
+	Message string
+}
+
+// Write a commit of the given tree on top of parent (which is zero for the first commit). The same signature is used
+// for the author and committer, so replaying the same history always gives the same commits
+func (r *Repository) WriteCommit(tree Hash, parent Hash, author Signature, message string) (Hash, error) {
+	var data bytes.Buffer
+	fmt.Fprintf(&data, "tree %s\n", tree)
+	if !parent.IsZero() {
+		fmt.Fprintf(&data, "parent %s\n", parent)
+	}
+	fmt.Fprintf(&data, "author %s\n", author)
+	fmt.Fprintf(&data, "committer %s\n", author)
+	data.WriteString("\n")
+	data.WriteString(strings.TrimRight(message, "\n"))
+	data.WriteString("\n")
+	return r.writeObject("commit", data.Bytes())
+}
+
+// Read a commit written earlier
+func (r *Repository) ReadCommit(hash Hash) (Commit, error) {
+	data, err := r.readObject("commit", hash)
+	if err != nil {
+		return Commit{}, err
+	}
+	var commit Commit
+	parts := strings.SplitN(string(data), "\n\n", 2)
+	if len(parts) == 2 {
+		commit.Message = parts[1]
+	}
+	for _, line := range strings.Split(parts[0], "\n") {
+		switch {
+		case strings.HasPrefix(line, "tree "):
+			commit.Tree, err = ParseHash(line[len("tree "):])
+		case strings.HasPrefix(line, "parent "):
+			commit.Parent, err = ParseHash(line[len("parent "):])
+		}
+		if err != nil {
+			return Commit{}, err
+		}
+	}
+	if commit.Tree.IsZero() {
+		return Commit{}, fmt.Errorf("Git commit %s has no tree", hash)
+	}
+	return commit, nil
+}
@@ -0,0 +1,172 @@
+// This package writes git repositories without needing the git binary. It only supports what mwexport needs: bare
+// repositories of loose objects, with a single branch that only ever grows
+package gitrepo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The branch that commits go on
+const Branch = "master"
+
+// The sha1 of an object. The zero value means no object
+type Hash [sha1.Size]byte
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+func (h Hash) IsZero() bool {
+	return h == Hash{}
+}
+
+// Parse the hex form of a hash
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	raw, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(raw) != len(h) {
+		return h, fmt.Errorf("Invalid hash: %s", s)
+	}
+	copy(h[:], raw)
+	return h, nil
+}
+
+// A bare git repository on disk
+type Repository struct {
+	dir string
+}
+
+// Open the bare repository in dir, creating it if needed
+func Open(dir string) (*Repository, error) {
+	r := &Repository{dir: dir}
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err == nil {
+		return r, nil
+	}
+	for _, sub := range []string{"objects", filepath.Join("refs", "heads"), filepath.Join("refs", "tags")} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("Cannot create git repository in %s: %v", dir, err)
+		}
+	}
+	files := map[string]string{
+		"HEAD":        "ref: refs/heads/" + Branch + "\n",
+		"config":      "[core]\n\trepositoryformatversion = 0\n\tfilemode = true\n\tbare = true\n",
+		"description": "Exported by mwexport\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("Cannot create git repository in %s: %v", dir, err)
+		}
+	}
+	return r, nil
+}
+
+// Where a loose object is stored
+func (r *Repository) objectPath(hash Hash) string {
+	hex := hash.String()
+	return filepath.Join(r.dir, "objects", hex[:2], hex[2:])
+}
+
+// Store an object of the given type (blob, tree or commit), unless it is already there. Safe for concurrent use
+func (r *Repository) writeObject(kind string, data []byte) (Hash, error) {
+	header := fmt.Sprintf("%s %d\x00", kind, len(data))
+	hasher := sha1.New()
+	hasher.Write([]byte(header))
+	hasher.Write(data)
+	var hash Hash
+	copy(hash[:], hasher.Sum(nil))
+	path := r.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write([]byte(header))
+	writer.Write(data)
+	if err := writer.Close(); err != nil {
+		return hash, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return hash, err
+	}
+	// Objects are written to a temporary file first, so a half-written object never has a valid name
+	temp, err := ioutil.TempFile(filepath.Dir(path), "tmp_obj_")
+	if err != nil {
+		return hash, err
+	}
+	_, err = temp.Write(compressed.Bytes())
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), 0444)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return hash, err
+}
+
+// Read an object, checking that it has the expected type
+func (r *Repository) readObject(kind string, hash Hash) ([]byte, error) {
+	compressed, err := ioutil.ReadFile(r.objectPath(hash))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read git object %s: %v", hash, err)
+	}
+	reader, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("Corrupt git object %s: %v", hash, err)
+	}
+	defer reader.Close()
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("Corrupt git object %s: %v", hash, err)
+	}
+	nul := bytes.IndexByte(raw, 0)
+	if nul < 0 || !bytes.HasPrefix(raw, []byte(kind+" ")) {
+		return nil, fmt.Errorf("Git object %s is not a %s", hash, kind)
+	}
+	return raw[nul+1:], nil
+}
+
+// Store file content
+func (r *Repository) WriteBlob(content []byte) (Hash, error) {
+	return r.writeObject("blob", content)
+}
+
+// Read file content
+func (r *Repository) ReadBlob(hash Hash) ([]byte, error) {
+	return r.readObject("blob", hash)
+}
+
+// The commit at the tip of the branch, or the zero hash if there are no commits yet
+func (r *Repository) Head() (Hash, error) {
+	data, err := ioutil.ReadFile(filepath.Join(r.dir, "refs", "heads", Branch))
+	if os.IsNotExist(err) {
+		return Hash{}, nil
+	}
+	if err != nil {
+		return Hash{}, err
+	}
+	return ParseHash(string(data))
+}
+
+// Move the tip of the branch to the given commit
+func (r *Repository) SetHead(commit Hash) error {
+	path := filepath.Join(r.dir, "refs", "heads", Branch)
+	temp := path + ".lock"
+	if err := ioutil.WriteFile(temp, []byte(commit.String()+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
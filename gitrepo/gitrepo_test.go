@@ -0,0 +1,154 @@
+package gitrepo
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setup(t *testing.T) (*Repository, string) {
+	dir, err := ioutil.TempDir("", "gitrepo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return repo, dir
+}
+
+// Check the repository with the real git, when it is installed
+func fsck(t *testing.T, dir string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Log("No git found, skipping fsck")
+		return
+	}
+	output, err := exec.Command("git", "--git-dir", dir, "fsck", "--strict").CombinedOutput()
+	if err != nil {
+		t.Errorf("git fsck failed: %v\n%s", err, output)
+	}
+}
+
+func TestWriteBlob(t *testing.T) {
+	repo, _ := setup(t)
+	hash, err := repo.WriteBlob([]byte("hello\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if hash.String() != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("Wrong hash: %s", hash)
+	}
+	content, err := repo.ReadBlob(hash)
+	if err != nil || string(content) != "hello\n" {
+		t.Errorf("Wrong content: %q %v", content, err)
+	}
+	// Writing it again leaves the read-only object alone
+	if again, err := repo.WriteBlob([]byte("hello\n")); err != nil || again != hash {
+		t.Errorf("Wrong second write: %s %v", again, err)
+	}
+}
+
+func TestWriteEmptyTree(t *testing.T) {
+	repo, _ := setup(t)
+	hash, err := repo.WriteTree(NewTree())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if hash.String() != "4b825dc642cb6eb9a060e54bf8d69288fbee4904" {
+		t.Errorf("Wrong hash: %s", hash)
+	}
+}
+
+func TestCommits(t *testing.T) {
+	repo, dir := setup(t)
+	hello, _ := repo.WriteBlob([]byte("hello\n"))
+	world, _ := repo.WriteBlob([]byte("world\n"))
+	tree := NewTree()
+	tree.Set("Main/Home.txt", hello)
+	tree.Set("Main.txt", world)
+	tree.Set("Main/Other.txt", world)
+	first, err := repo.WriteTree(tree)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	author := Signature{Name: "Alice <admin>", Email: "Alice@wiki.example.org", When: time.Date(2020, 1, 1, 12, 0, 0, 0, time.FixedZone("", -90*60))}
+	base, err := repo.WriteCommit(first, Hash{}, author, "Created page\n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !tree.Remove("Main/Home.txt") || !tree.Remove("Main/Other.txt") || tree.Remove("Main/Home.txt") {
+		t.Errorf("Wrong removals")
+	}
+	tree.Set("Help/Edit.txt", hello)
+	second, _ := repo.WriteTree(tree)
+	tip, err := repo.WriteCommit(second, base, author, "Moved page")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = repo.SetHead(tip); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil || head != tip {
+		t.Errorf("Wrong head: %s %v", head, err)
+	}
+	commit, err := repo.ReadCommit(head)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if commit.Tree != second || commit.Parent != base || commit.Message != "Moved page\n" {
+		t.Errorf("Wrong commit: %+v", commit)
+	}
+	read, err := repo.ReadTree(commit.Tree)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	files := read.Files()
+	if len(files) != 2 || files["Main.txt"] != world || files["Help/Edit.txt"] != hello {
+		t.Errorf("Wrong files: %v", files)
+	}
+	if blob, found := read.Get("Help/Edit.txt"); !found || blob != hello {
+		t.Errorf("Wrong blob: %s", blob)
+	}
+	if _, found := read.Get("Main/Home.txt"); found {
+		t.Errorf("Removed file is still there")
+	}
+	raw, _ := repo.readObject("commit", base)
+	if !strings.Contains(string(raw), "author Alice admin <Alice@wiki.example.org> 1577885400 -0130\n") {
+		t.Errorf("Wrong author: %s", raw)
+	}
+	fsck(t, dir)
+	if _, err := exec.LookPath("git"); err == nil {
+		output, err := exec.Command("git", "--git-dir", dir, "log", "--format=%an %s", Branch).CombinedOutput()
+		if err != nil || string(output) != "Alice admin Moved page\nAlice admin Created page\n" {
+			t.Errorf("Wrong git log: %v\n%s", err, output)
+		}
+	}
+}
+
+func TestOpenExisting(t *testing.T) {
+	repo, dir := setup(t)
+	if head, err := repo.Head(); err != nil || !head.IsZero() {
+		t.Errorf("New repository has a head: %s %v", head, err)
+	}
+	tree, _ := repo.WriteTree(NewTree())
+	commit, _ := repo.WriteCommit(tree, Hash{}, Signature{Name: "Bob", Email: "Bob@wiki", When: time.Unix(0, 0)}, "Empty")
+	repo.SetHead(commit)
+	again, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if head, err := again.Head(); err != nil || head != commit {
+		t.Errorf("Wrong head: %s %v", head, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "refs", "heads", Branch+".lock")); !os.IsNotExist(err) {
+		t.Errorf("Lock file left behind: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package gitrepo
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The files of a commit being built, by their slash separated paths. Only the trees of directories that changed are
+// written again, so committing one changed file is cheap however many files there are
+type Tree struct {
+	root *treeNode
+}
+
+type treeNode struct {
+	files map[string]Hash
+	dirs  map[string]*treeNode
+	hash  Hash // Zero while the directory has unwritten changes
+}
+
+func newTreeNode() *treeNode {
+	return &treeNode{files: make(map[string]Hash), dirs: make(map[string]*treeNode)}
+}
+
+// An empty tree
+func NewTree() *Tree {
+	return &Tree{root: newTreeNode()}
+}
+
+// Put a blob at the given path, replacing whatever was there
+func (t *Tree) Set(path string, blob Hash) {
+	parts := strings.Split(path, "/")
+	node := t.root
+	for _, dir := range parts[:len(parts)-1] {
+		node.hash = Hash{}
+		child, found := node.dirs[dir]
+		if !found {
+			child = newTreeNode()
+			node.dirs[dir] = child
+		}
+		node = child
+	}
+	node.hash = Hash{}
+	node.files[parts[len(parts)-1]] = blob
+}
+
+// The blob at the given path, if there is one
+func (t *Tree) Get(path string) (Hash, bool) {
+	parts := strings.Split(path, "/")
+	node := t.root
+	for _, dir := range parts[:len(parts)-1] {
+		if node = node.dirs[dir]; node == nil {
+			return Hash{}, false
+		}
+	}
+	hash, found := node.files[parts[len(parts)-1]]
+	return hash, found
+}
+
+// Remove the file at the given path, and any directories that become empty. Returns whether there was one
+func (t *Tree) Remove(path string) bool {
+	return t.root.remove(strings.Split(path, "/"))
+}
+
+func (n *treeNode) remove(parts []string) bool {
+	if len(parts) == 1 {
+		if _, found := n.files[parts[0]]; !found {
+			return false
+		}
+		delete(n.files, parts[0])
+		n.hash = Hash{}
+		return true
+	}
+	child, found := n.dirs[parts[0]]
+	if !found || !child.remove(parts[1:]) {
+		return false
+	}
+	if len(child.files) == 0 && len(child.dirs) == 0 {
+		delete(n.dirs, parts[0])
+	}
+	n.hash = Hash{}
+	return true
+}
+
+// Every file in the tree, by path
+func (t *Tree) Files() map[string]Hash {
+	files := make(map[string]Hash)
+	t.root.collect("", files)
+	return files
+}
+
+func (n *treeNode) collect(prefix string, files map[string]Hash) {
+	for name, hash := range n.files {
+		files[prefix+name] = hash
+	}
+	for name, child := range n.dirs {
+		child.collect(prefix+name+"/", files)
+	}
+}
+
+// Write every changed directory, returning the hash of the root tree
+func (r *Repository) WriteTree(t *Tree) (Hash, error) {
+	return r.writeTreeNode(t.root)
+}
+
+func (r *Repository) writeTreeNode(n *treeNode) (Hash, error) {
+	if !n.hash.IsZero() {
+		return n.hash, nil
+	}
+	type entry struct {
+		mode string
+		name string
+		hash Hash
+	}
+	var entries []entry
+	for name, hash := range n.files {
+		entries = append(entries, entry{"100644", name, hash})
+	}
+	for name, child := range n.dirs {
+		hash, err := r.writeTreeNode(child)
+		if err != nil {
+			return Hash{}, err
+		}
+		entries = append(entries, entry{"40000", name, hash})
+	}
+	// Git sorts directories as if their names ended with a slash
+	sortKey := func(e entry) string {
+		if e.mode == "40000" {
+			return e.name + "/"
+		}
+		return e.name
+	}
+	sort.Slice(entries, func(i, j int) bool { return sortKey(entries[i]) < sortKey(entries[j]) })
+	var data bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&data, "%s %s\x00", e.mode, e.name)
+		data.Write(e.hash[:])
+	}
+	hash, err := r.writeObject("tree", data.Bytes())
+	if err != nil {
+		return Hash{}, err
+	}
+	n.hash = hash
+	return hash, nil
+}
+
+// Read a tree written earlier, along with all its subdirectories
+func (r *Repository) ReadTree(hash Hash) (*Tree, error) {
+	node, err := r.readTreeNode(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{root: node}, nil
+}
+
+func (r *Repository) readTreeNode(hash Hash) (*treeNode, error) {
+	data, err := r.readObject("tree", hash)
+	if err != nil {
+		return nil, err
+	}
+	node := newTreeNode()
+	for len(data) > 0 {
+		nul := bytes.IndexByte(data, 0)
+		if nul < 0 || len(data) < nul+1+len(hash) {
+			return nil, fmt.Errorf("Corrupt git tree %s", hash)
+		}
+		header := strings.SplitN(string(data[:nul]), " ", 2)
+		if len(header) != 2 {
+			return nil, fmt.Errorf("Corrupt git tree %s", hash)
+		}
+		var child Hash
+		copy(child[:], data[nul+1:])
+		data = data[nul+1+len(child):]
+		if header[0] == "40000" {
+			if node.dirs[header[1]], err = r.readTreeNode(child); err != nil {
+				return nil, err
+			}
+		} else {
+			node.files[header[1]] = child
+		}
+	}
+	node.hash = hash
+	return node, nil
+}
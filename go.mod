@@ -0,0 +1,11 @@
+module github.com/stevearm/mediawiki-export
+
+go 1.17
+
+require (
+	github.com/golang/glog v0.0.0-20210429001901-424d2337a529
+	github.com/golang/mock v1.6.0
+	golang.org/x/term v0.10.0
+)
+
+require golang.org/x/sys v0.10.0 // indirect
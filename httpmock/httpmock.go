@@ -22,9 +22,10 @@ const (
 
 // Used to capture http requests that the server receives
 type Request struct {
-	Method Method
-	Url    string
-	Body   string
+	Method    Method
+	Url       string
+	Body      string
+	UserAgent string
 }
 
 // Pass one of these to Server.QueueResponse() to setup the server for
@@ -33,6 +34,7 @@ type Response struct {
 	ResponseCode int
 	ContentType  string
 	Content      string
+	Headers      map[string]string // Any extra headers to send
 }
 
 // Generic error response usually used as the default response when
@@ -66,7 +68,8 @@ func (s *Server) Init(defaultResponse Response) *http.Client {
 	s.requests = make(chan Request, QUEUE_LEN)
 	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
 		req := Request{
-			Url: request.URL.String(),
+			Url:       request.URL.String(),
+			UserAgent: request.UserAgent(),
 		}
 		switch request.Method {
 		case "GET":
@@ -99,8 +102,13 @@ func (s *Server) Init(defaultResponse Response) *http.Client {
 		default:
 			break
 		}
+		if response.ContentType != "" {
+			w.Header().Set("Content-Type", response.ContentType)
+		}
+		for name, value := range response.Headers {
+			w.Header().Set(name, value)
+		}
 		w.WriteHeader(response.ResponseCode)
-		w.Header().Set("Content-Type", response.ContentType)
 		fmt.Fprint(w, response.Content)
 	}))
 
@@ -44,7 +44,7 @@ func TestServerDefaultCustomResponse(t *testing.T) {
 		t.Errorf("Wrong status: %v", resp.StatusCode)
 	}
 	contentType := resp.Header["Content-Type"]
-	if len(contentType) != 1 || strings.Contains(contentType[0], "text/custom") {
+	if len(contentType) != 1 || !strings.Contains(contentType[0], "text/custom") {
 		t.Errorf("Wrong content type: %v", contentType)
 	}
 	body, err := ioutil.ReadAll(resp.Body)
@@ -79,7 +79,7 @@ func TestServerExpectedRequest(t *testing.T) {
 		t.Errorf("Wrong status: %v", resp.StatusCode)
 	}
 	contentType := resp.Header["Content-Type"]
-	if len(contentType) != 1 || strings.Contains(contentType[0], "text/custom") {
+	if len(contentType) != 1 || !strings.Contains(contentType[0], "text/custom") {
 		t.Errorf("Wrong content type: %v", contentType)
 	}
 	body, err := ioutil.ReadAll(resp.Body)
@@ -102,6 +102,29 @@ func TestServerExpectedRequest(t *testing.T) {
 	}
 }
 
+func TestServerCustomHeaders(t *testing.T) {
+	server := &Server{}
+	client := server.Init(ErrorResponse())
+	defer server.Close()
+
+	server.QueueResponse(Response{
+		ResponseCode: 503,
+		Headers:      map[string]string{"Retry-After": "5"},
+	})
+
+	resp, err := client.Get("http://example.org/path")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 503 {
+		t.Errorf("Wrong status: %v", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") != "5" {
+		t.Errorf("Wrong headers: %v", resp.Header)
+	}
+}
+
 func TestServerGetRequest(t *testing.T) {
 	server := &Server{}
 	client := server.Init(ErrorResponse())
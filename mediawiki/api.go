@@ -2,40 +2,122 @@
 package mediawiki
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"strconv"
 	"sync"
-
-	"github.com/golang/glog"
+	"time"
 )
 
-// An api client. Either call Client.Login(), or it will auto-login on first use
+// An api client. Either call Client.Login(), or it will auto-login on first use. Every method has a Context variant
+// that gives up as soon as the context is cancelled
 type Client interface {
 	Login() error
+	LoginContext(ctx context.Context) error
 	ListArticleTitles() ([]string, error)
+	ListArticleTitlesContext(ctx context.Context) ([]string, error)
+	ListNamespaces() ([]Namespace, error)
+	ListNamespacesContext(ctx context.Context) ([]Namespace, error)
+	GetSiteInfo() (SiteInfo, error)
+	GetSiteInfoContext(ctx context.Context) (SiteInfo, error)
+	ListTitles(namespaces []int) ([]Title, error)
+	ListTitlesContext(ctx context.Context, namespaces []int) ([]Title, error)
 	GetArticle(title string) (string, error)
+	GetArticleContext(ctx context.Context, title string) (string, error)
+	GetPages(titles []string, followRedirects bool) ([]Page, error)
+	GetPagesContext(ctx context.Context, titles []string, followRedirects bool) ([]Page, error)
+	GetRedirects(titles []string) (map[string]string, error)
+	GetRedirectsContext(ctx context.Context, titles []string) (map[string]string, error)
+	RecentChanges(since time.Time, namespaces []int) ([]Change, error)
+	RecentChangesContext(ctx context.Context, since time.Time, namespaces []int) ([]Change, error)
+	LogEvents(logType string, since time.Time) ([]LogEvent, error)
+	LogEventsContext(ctx context.Context, logType string, since time.Time) ([]LogEvent, error)
+	GetHistory(title string) ([]Revision, error)
+	GetHistoryContext(ctx context.Context, title string) ([]Revision, error)
+	GetHistoryAfter(title string, revisionID int) ([]Revision, error)
+	GetHistoryAfterContext(ctx context.Context, title string, revisionID int) ([]Revision, error)
+	ListFiles() ([]File, error)
+	ListFilesContext(ctx context.Context) ([]File, error)
+	GetFiles(titles []string) ([]File, error)
+	GetFilesContext(ctx context.Context, titles []string) ([]File, error)
+	DownloadFile(file File, w io.Writer) error
+	DownloadFileContext(ctx context.Context, file File, w io.Writer) error
+	Edit(edit Edit) (EditResult, error)
+	EditContext(ctx context.Context, edit Edit) (EditResult, error)
+}
+
+// Everything needed to connect to a wiki
+type Config struct {
+	Site     Site
+	Username string // Either an account name, or a bot password name such as "Account@botname". Empty to stay anonymous
+	Password string
+	// Log in with action=clientlogin instead of action=login. Bot passwords always use action=login
+	ClientLogin bool
+	// Give up on any single HTTP request that takes longer than this. Zero for no limit
+	Timeout time.Duration
+	// How to retry requests that fail for temporary reasons. The zero value never retries
+	Retry RetryPolicy
+	// Ask the server to refuse requests (which are then retried) while its database replicas lag by more than this
+	// many seconds, as recommended for bots. Zero to not send maxlag
+	MaxLag int
+	// Most requests per second to send on average, shared by everything the client does. Zero for no limit
+	RateLimit float64
+	// How many requests can go out back to back before RateLimit kicks in. Defaults to one
+	RateBurst int
+	// Identifies the tool to the wiki's operators, ideally with a way to contact whoever runs it. Defaults to
+	// DefaultUserAgent
+	UserAgent string
 }
 
-func GetClient(host, username, password string) Client {
-	return &client{
-		host:     host,
-		username: username,
-		password: password,
+// Sent when no User-Agent is configured. Wikimedia and other hosts block generic client User-Agents
+const DefaultUserAgent = "mediawiki-export (https://github.com/stevearm/mediawiki-export)"
+
+func GetClient(config Config) Client {
+	userAgent := config.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	c := &client{
+		site:        config.Site,
+		username:    config.Username,
+		password:    config.Password,
+		clientLogin: config.ClientLogin,
+		timeout:     config.Timeout,
+		retry:       config.Retry,
+		maxLag:      config.MaxLag,
+		sleep:       sleepContext,
+		limiter:     newRateLimiter(config.RateLimit, config.RateBurst, sleepContext),
+		userAgent:   userAgent,
 	}
+	c.initHttpClient()
+	return c
 }
 
+// Safe for concurrent use, with a lock for each thing that is set up lazily
 type client struct {
-	host       string
-	username   string
-	password   string
-	httpClient *http.Client
-	loginError error
-	authLock   sync.Once
+	site        Site
+	username    string
+	password    string
+	clientLogin bool
+	timeout     time.Duration
+	retry       RetryPolicy
+	maxLag      int
+	sleep       func(ctx context.Context, wait time.Duration) error
+	limiter     *rateLimiter
+	userAgent   string
+	httpClient  *http.Client
+	loggedIn    bool
+	loginError  error
+	authLock    sync.Mutex
+	batchSize   int // Pages per prop=revisions query, found on first use
+	limitsLock  sync.Mutex
+	csrfToken   string // For edits, fetched on first use
+	tokenLock   sync.Mutex
 }
 
 func (c *client) initHttpClient() {
@@ -43,113 +125,89 @@ func (c *client) initHttpClient() {
 		// Setup an http client that manages cookies
 		cookieJar, _ := cookiejar.New(nil)
 		c.httpClient = &http.Client{
-			Jar: cookieJar,
+			Jar:     cookieJar,
+			Timeout: c.timeout,
 		}
 	}
 }
 
-func (c *client) login() {
-	c.initHttpClient()
-	glog.Info("Logging in")
-	type loginResponseInner struct {
-		Result string `json:"result"`
-		Token  string `json:"token"`
-	}
-	type loginResponse struct {
-		Login *loginResponseInner `json:"login"`
-	}
+func (c *client) apiUrl() string {
+	return c.site.ApiUrl()
+}
 
-	// Make the first call to get a token
-	glog.V(1).Info("Making 1/2 HTTP calls")
+// Get the url for a POSTed api action, whose other parameters go in the body
+func (c *client) actionUrl(action string) string {
 	values := make(url.Values)
-	loginUrl := fmt.Sprintf("http://%s/api.php?action=login&lgname=%s&lgpassword=%s&format=json", c.host, c.username, c.password)
-	res, err := c.httpClient.PostForm(loginUrl, values)
-	glog.V(2).Info("Finished 1/2 HTTP calls")
-	if err != nil {
-		c.loginError = err
-		return
-	}
-	defer res.Body.Close()
-	decoder := json.NewDecoder(res.Body)
-	var response loginResponse
-	c.loginError = decoder.Decode(&response)
-	if c.loginError != nil {
-		return
-	}
-	if response.Login == nil {
-		c.loginError = errors.New("Missing login object from response")
-		return
-	}
-	if response.Login.Result != "NeedToken" {
-		c.loginError = errors.New("Did not ask for token")
-		return
+	values.Set("action", action)
+	values.Set("format", "json")
+	if c.maxLag > 0 {
+		values.Set("maxlag", strconv.Itoa(c.maxLag))
 	}
-
-	// Do the same call, this time passing back the token
-	values.Set("lgtoken", response.Login.Token)
-	glog.V(1).Info("Making 2/2 HTTP calls")
-	res, c.loginError = c.httpClient.PostForm(loginUrl, values)
-	glog.V(2).Info("Finished 2/2 HTTP calls")
-	if c.loginError != nil {
-		return
-	}
-	defer res.Body.Close()
+	return fmt.Sprintf("%s?%s", c.apiUrl(), values.Encode())
 }
 
 // Ensure the client instance is properly logged in
 func (c *client) Login() error {
-	c.authLock.Do(c.login)
-	return c.loginError
+	return c.LoginContext(context.Background())
+}
+
+// Ensure the client instance is properly logged in. A login that fails is not retried, unless it failed because the
+// context was cancelled
+func (c *client) LoginContext(ctx context.Context) error {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	if c.loggedIn {
+		return c.loginError
+	}
+	err := c.login(ctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	c.loggedIn = true
+	c.loginError = err
+	return err
 }
 
-// Get a list of all the articles contained in the wiki
+// Get a list of all the articles contained in the main namespace of the wiki
 func (c *client) ListArticleTitles() ([]string, error) {
-	c.authLock.Do(c.login)
-	if c.loginError != nil {
-		return nil, c.loginError
-	}
-	glog.Info("Listing all articles")
-	type page struct {
-		Title string `json:"title"`
-	}
-	type query struct {
-		AllPages []page `json:"allpages"`
-	}
-	type result struct {
-		Query query `json:"query"`
-	}
-	listUrl := fmt.Sprintf("http://%s/api.php?format=json&action=query&list=allpages&aplimit=max", c.host)
-	res, err := c.httpClient.Get(listUrl)
+	return c.ListArticleTitlesContext(context.Background())
+}
+
+// Get a list of all the articles contained in the main namespace of the wiki
+func (c *client) ListArticleTitlesContext(ctx context.Context) ([]string, error) {
+	pages, err := c.ListTitlesContext(ctx, []int{0})
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-	decoder := json.NewDecoder(res.Body)
-	var response result
-	err = decoder.Decode(&response)
-	if err != nil {
-		return nil, err
-	}
-
-	titles := make([]string, len(response.Query.AllPages))
-	for i := 0; i < len(titles); i++ {
-		titles[i] = response.Query.AllPages[i].Title
+	titles := make([]string, len(pages))
+	for i, page := range pages {
+		titles[i] = page.Title
 	}
 	return titles, nil
 }
 
 // Get the raw wikitext of an article
-func (c client) GetArticle(title string) (string, error) {
-	c.authLock.Do(c.login)
-	if c.loginError != nil {
-		return "", c.loginError
+func (c *client) GetArticle(title string) (string, error) {
+	return c.GetArticleContext(context.Background(), title)
+}
+
+// Get the raw wikitext of an article
+func (c *client) GetArticleContext(ctx context.Context, title string) (string, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return "", err
 	}
-	articleUrl := fmt.Sprintf("http://%s/index.php?action=raw&title=%s", c.host, url.QueryEscape(title))
-	resp, err := c.httpClient.Get(articleUrl)
+	articleUrl := fmt.Sprintf("%s?action=raw&title=%s", c.site.IndexUrl(), url.QueryEscape(title))
+	resp, err := c.get(ctx, articleUrl)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("Article not found: %s", title)
+	}
+	if err = c.checkResponse(resp); err != nil {
+		return "", err
+	}
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
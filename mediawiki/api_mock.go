@@ -4,7 +4,10 @@
 package mediawiki
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
+	io "io"
+	time "time"
 )
 
 // Mock of Client interface
@@ -38,6 +41,16 @@ func (_mr *_MockClientRecorder) Login() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Login")
 }
 
+func (_m *MockClient) LoginContext(ctx context.Context) error {
+	ret := _m.ctrl.Call(_m, "LoginContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockClientRecorder) LoginContext(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LoginContext", arg0)
+}
+
 func (_m *MockClient) ListArticleTitles() ([]string, error) {
 	ret := _m.ctrl.Call(_m, "ListArticleTitles")
 	ret0, _ := ret[0].([]string)
@@ -49,6 +62,83 @@ func (_mr *_MockClientRecorder) ListArticleTitles() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListArticleTitles")
 }
 
+func (_m *MockClient) ListArticleTitlesContext(ctx context.Context) ([]string, error) {
+	ret := _m.ctrl.Call(_m, "ListArticleTitlesContext", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) ListArticleTitlesContext(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListArticleTitlesContext", arg0)
+}
+
+func (_m *MockClient) ListNamespaces() ([]Namespace, error) {
+	ret := _m.ctrl.Call(_m, "ListNamespaces")
+	ret0, _ := ret[0].([]Namespace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) ListNamespaces() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListNamespaces")
+}
+
+func (_m *MockClient) ListNamespacesContext(ctx context.Context) ([]Namespace, error) {
+	ret := _m.ctrl.Call(_m, "ListNamespacesContext", ctx)
+	ret0, _ := ret[0].([]Namespace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) ListNamespacesContext(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListNamespacesContext", arg0)
+}
+
+func (_m *MockClient) GetSiteInfo() (SiteInfo, error) {
+	ret := _m.ctrl.Call(_m, "GetSiteInfo")
+	ret0, _ := ret[0].(SiteInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetSiteInfo() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSiteInfo")
+}
+
+func (_m *MockClient) GetSiteInfoContext(ctx context.Context) (SiteInfo, error) {
+	ret := _m.ctrl.Call(_m, "GetSiteInfoContext", ctx)
+	ret0, _ := ret[0].(SiteInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetSiteInfoContext(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSiteInfoContext", arg0)
+}
+
+func (_m *MockClient) ListTitles(namespaces []int) ([]Title, error) {
+	ret := _m.ctrl.Call(_m, "ListTitles", namespaces)
+	ret0, _ := ret[0].([]Title)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) ListTitles(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListTitles", arg0)
+}
+
+func (_m *MockClient) ListTitlesContext(ctx context.Context, namespaces []int) ([]Title, error) {
+	ret := _m.ctrl.Call(_m, "ListTitlesContext", ctx, namespaces)
+	ret0, _ := ret[0].([]Title)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) ListTitlesContext(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListTitlesContext", arg0, arg1)
+}
+
 func (_m *MockClient) GetArticle(title string) (string, error) {
 	ret := _m.ctrl.Call(_m, "GetArticle", title)
 	ret0, _ := ret[0].(string)
@@ -59,3 +149,232 @@ func (_m *MockClient) GetArticle(title string) (string, error) {
 func (_mr *_MockClientRecorder) GetArticle(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetArticle", arg0)
 }
+
+func (_m *MockClient) GetArticleContext(ctx context.Context, title string) (string, error) {
+	ret := _m.ctrl.Call(_m, "GetArticleContext", ctx, title)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetArticleContext(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetArticleContext", arg0, arg1)
+}
+
+func (_m *MockClient) GetPages(titles []string, followRedirects bool) ([]Page, error) {
+	ret := _m.ctrl.Call(_m, "GetPages", titles, followRedirects)
+	ret0, _ := ret[0].([]Page)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetPages(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetPages", arg0, arg1)
+}
+
+func (_m *MockClient) GetPagesContext(ctx context.Context, titles []string, followRedirects bool) ([]Page, error) {
+	ret := _m.ctrl.Call(_m, "GetPagesContext", ctx, titles, followRedirects)
+	ret0, _ := ret[0].([]Page)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetPagesContext(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetPagesContext", arg0, arg1, arg2)
+}
+
+func (_m *MockClient) GetRedirects(titles []string) (map[string]string, error) {
+	ret := _m.ctrl.Call(_m, "GetRedirects", titles)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetRedirects(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRedirects", arg0)
+}
+
+func (_m *MockClient) GetRedirectsContext(ctx context.Context, titles []string) (map[string]string, error) {
+	ret := _m.ctrl.Call(_m, "GetRedirectsContext", ctx, titles)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetRedirectsContext(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRedirectsContext", arg0, arg1)
+}
+
+func (_m *MockClient) RecentChanges(since time.Time, namespaces []int) ([]Change, error) {
+	ret := _m.ctrl.Call(_m, "RecentChanges", since, namespaces)
+	ret0, _ := ret[0].([]Change)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) RecentChanges(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RecentChanges", arg0, arg1)
+}
+
+func (_m *MockClient) RecentChangesContext(ctx context.Context, since time.Time, namespaces []int) ([]Change, error) {
+	ret := _m.ctrl.Call(_m, "RecentChangesContext", ctx, since, namespaces)
+	ret0, _ := ret[0].([]Change)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) RecentChangesContext(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RecentChangesContext", arg0, arg1, arg2)
+}
+
+func (_m *MockClient) LogEvents(logType string, since time.Time) ([]LogEvent, error) {
+	ret := _m.ctrl.Call(_m, "LogEvents", logType, since)
+	ret0, _ := ret[0].([]LogEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) LogEvents(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LogEvents", arg0, arg1)
+}
+
+func (_m *MockClient) LogEventsContext(ctx context.Context, logType string, since time.Time) ([]LogEvent, error) {
+	ret := _m.ctrl.Call(_m, "LogEventsContext", ctx, logType, since)
+	ret0, _ := ret[0].([]LogEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) LogEventsContext(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LogEventsContext", arg0, arg1, arg2)
+}
+
+func (_m *MockClient) GetHistory(title string) ([]Revision, error) {
+	ret := _m.ctrl.Call(_m, "GetHistory", title)
+	ret0, _ := ret[0].([]Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetHistory(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetHistory", arg0)
+}
+
+func (_m *MockClient) GetHistoryContext(ctx context.Context, title string) ([]Revision, error) {
+	ret := _m.ctrl.Call(_m, "GetHistoryContext", ctx, title)
+	ret0, _ := ret[0].([]Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetHistoryContext(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetHistoryContext", arg0, arg1)
+}
+
+func (_m *MockClient) GetHistoryAfter(title string, revisionID int) ([]Revision, error) {
+	ret := _m.ctrl.Call(_m, "GetHistoryAfter", title, revisionID)
+	ret0, _ := ret[0].([]Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetHistoryAfter(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetHistoryAfter", arg0, arg1)
+}
+
+func (_m *MockClient) GetHistoryAfterContext(ctx context.Context, title string, revisionID int) ([]Revision, error) {
+	ret := _m.ctrl.Call(_m, "GetHistoryAfterContext", ctx, title, revisionID)
+	ret0, _ := ret[0].([]Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetHistoryAfterContext(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetHistoryAfterContext", arg0, arg1, arg2)
+}
+
+func (_m *MockClient) ListFiles() ([]File, error) {
+	ret := _m.ctrl.Call(_m, "ListFiles")
+	ret0, _ := ret[0].([]File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) ListFiles() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListFiles")
+}
+
+func (_m *MockClient) ListFilesContext(ctx context.Context) ([]File, error) {
+	ret := _m.ctrl.Call(_m, "ListFilesContext", ctx)
+	ret0, _ := ret[0].([]File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) ListFilesContext(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListFilesContext", arg0)
+}
+
+func (_m *MockClient) GetFiles(titles []string) ([]File, error) {
+	ret := _m.ctrl.Call(_m, "GetFiles", titles)
+	ret0, _ := ret[0].([]File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetFiles(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetFiles", arg0)
+}
+
+func (_m *MockClient) GetFilesContext(ctx context.Context, titles []string) ([]File, error) {
+	ret := _m.ctrl.Call(_m, "GetFilesContext", ctx, titles)
+	ret0, _ := ret[0].([]File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) GetFilesContext(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetFilesContext", arg0, arg1)
+}
+
+func (_m *MockClient) DownloadFile(file File, w io.Writer) error {
+	ret := _m.ctrl.Call(_m, "DownloadFile", file, w)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockClientRecorder) DownloadFile(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DownloadFile", arg0, arg1)
+}
+
+func (_m *MockClient) DownloadFileContext(ctx context.Context, file File, w io.Writer) error {
+	ret := _m.ctrl.Call(_m, "DownloadFileContext", ctx, file, w)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockClientRecorder) DownloadFileContext(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DownloadFileContext", arg0, arg1, arg2)
+}
+
+func (_m *MockClient) Edit(edit Edit) (EditResult, error) {
+	ret := _m.ctrl.Call(_m, "Edit", edit)
+	ret0, _ := ret[0].(EditResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) Edit(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Edit", arg0)
+}
+
+func (_m *MockClient) EditContext(ctx context.Context, edit Edit) (EditResult, error) {
+	ret := _m.ctrl.Call(_m, "EditContext", ctx, edit)
+	ret0, _ := ret[0].(EditResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockClientRecorder) EditContext(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "EditContext", arg0, arg1)
+}
@@ -1,18 +1,22 @@
 package mediawiki
 
 import (
+	"context"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/stevearm/mediawiki-export/httpmock"
 )
 
-func setup() (Client, *httpmock.Server) {
+func setup() (*client, *httpmock.Server) {
 	server := &httpmock.Server{}
 	httpClient := server.Init(httpmock.ErrorResponse())
 
 	client := &client{
-		host:     "wiki.example.org",
+		site:     Site{Scheme: "http", Host: "wiki.example.org"},
 		username: "myuser",
 		password: "mypass",
 	}
@@ -28,23 +32,23 @@ func setupLoginResponses(server *httpmock.Server) {
 	server.QueueResponse(httpmock.Response{
 		ResponseCode: 200,
 		ContentType:  "application/json",
-		Content:      `{"login":{"result":"NeedToken","token":"tokenvalue1234abcd"}}`,
+		Content:      `{"batchcomplete":"","query":{"tokens":{"logintoken":"tokenvalue1234abcd+\\"}}}`,
 	})
 	server.QueueResponse(httpmock.Response{
 		ResponseCode: 200,
 		ContentType:  "application/json",
-		Content:      `{"login":{"result":"Done","token":"tokenvalue1234abcd"}}`,
+		Content:      `{"login":{"result":"Success","lguserid":1,"lgusername":"Myuser"}}`,
 	})
 }
 
 func checkLoginCalls(t *testing.T, requests <-chan httpmock.Request) {
 	request := <-requests
-	if request.Method != httpmock.PostMethod || request.Url != "http://wiki.example.org/api.php?action=login&lgname=myuser&lgpassword=mypass&format=json" || request.Body != "" {
-		t.Errorf("Bad first call: %v", request)
+	if request.Method != httpmock.GetMethod || request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&meta=tokens&type=login" {
+		t.Errorf("Bad token call: %v", request)
 	}
 	request = <-requests
-	if request.Method != httpmock.PostMethod || request.Url != "http://wiki.example.org/api.php?action=login&lgname=myuser&lgpassword=mypass&format=json" || request.Body != "lgtoken=tokenvalue1234abcd" {
-		t.Errorf("Bad first call: %v", request)
+	if request.Method != httpmock.PostMethod || request.Url != "http://wiki.example.org/api.php?action=login&format=json" || request.Body != "lgname=myuser&lgpassword=mypass&lgtoken=tokenvalue1234abcd%2B%5C" {
+		t.Errorf("Bad login call: %v", request)
 	}
 }
 
@@ -86,6 +90,210 @@ func TestLoginFailure(t *testing.T) {
 	}
 }
 
+func TestLoginEscapesCredentials(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	client.password = "p&ss=w%rd lgname=admin"
+	setupLoginResponses(server)
+	err := client.Login()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	requests := server.Requests()
+	<-requests
+	request := <-requests
+	if strings.Contains(request.Url, "ss") || strings.Contains(request.Url, "lgname") {
+		t.Errorf("Credentials leaked into the url: %v", request)
+	}
+	values, err := url.ParseQuery(request.Body)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(values["lgname"]) != 1 || values.Get("lgname") != "myuser" || len(values["lgpassword"]) != 1 || values.Get("lgpassword") != "p&ss=w%rd lgname=admin" {
+		t.Errorf("Credentials were not escaped: %v", request.Body)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	client := &client{password: "hunter2&more"}
+	assertRedact(t, client, "http://wiki.example.org/api.php?action=login&format=json", "http://wiki.example.org/api.php?action=login&format=json")
+	assertRedact(t, client, "lgname=myuser&lgpassword=hunter2%26more&lgtoken=abc%2B%5C", "lgname=myuser&lgpassword=REDACTED&lgtoken=REDACTED")
+	assertRedact(t, client, "logintoken=abc&password=x&username=myuser", "logintoken=REDACTED&password=REDACTED&username=myuser")
+	assertRedact(t, client, "Post http://wiki.example.org/?p=hunter2&more: EOF", "Post http://wiki.example.org/?p=REDACTED: EOF")
+	client.password = ""
+	assertRedact(t, client, "title=Main+Page", "title=Main+Page")
+}
+
+func assertRedact(t *testing.T, client *client, source, expected string) {
+	actual := client.redact(source)
+	if actual != expected {
+		t.Errorf("Redact %v to %v failed: %v", source, expected, actual)
+	}
+}
+
+func TestLegacyLogin(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"warnings":{"query":{"*":"Unrecognized value for parameter 'meta': tokens"}},"batchcomplete":""}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"login":{"result":"NeedToken","token":"tokenvalue1234abcd"}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"login":{"result":"Success","lguserid":1,"lgusername":"Myuser"}}`,
+	})
+	err := client.Login()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	requests := server.Requests()
+	<-requests
+	request := <-requests
+	if request.Method != httpmock.PostMethod || request.Url != "http://wiki.example.org/api.php?action=login&format=json" || request.Body != "lgname=myuser&lgpassword=mypass" {
+		t.Errorf("Bad first call: %v", request)
+	}
+	request = <-requests
+	if request.Method != httpmock.PostMethod || request.Url != "http://wiki.example.org/api.php?action=login&format=json" || request.Body != "lgname=myuser&lgpassword=mypass&lgtoken=tokenvalue1234abcd" {
+		t.Errorf("Bad second call: %v", request)
+	}
+	if len(requests) != 0 {
+		t.Errorf("Found extra requests: %v", len(requests))
+	}
+}
+
+func TestLegacyLoginNotDone(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":""}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"login":{"result":"NeedToken","token":"tokenvalue1234abcd"}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"login":{"result":"WrongPass"}}`,
+	})
+	err := client.Login()
+	if _, ok := err.(*WrongPasswordError); !ok {
+		t.Errorf("Expected a wrong password error: %v", err)
+	}
+}
+
+func TestLoginErrors(t *testing.T) {
+	assertLoginError(t, `{"login":{"result":"Failed","reason":"Incorrect username or password entered."}}`, &WrongPasswordError{})
+	assertLoginError(t, `{"login":{"result":"WrongPass"}}`, &WrongPasswordError{})
+	assertLoginError(t, `{"login":{"result":"Throttled","wait":300}}`, &ThrottledError{})
+	assertLoginError(t, `{"login":{"result":"Aborted","reason":"Cannot log in when using action=login"}}`, &UnsupportedLoginError{})
+	assertLoginError(t, `{"login":{"result":"Blocked"}}`, &LoginError{})
+}
+
+func assertLoginError(t *testing.T, content string, expected error) {
+	client, server := setup()
+	defer server.Close()
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"tokens":{"logintoken":"tokenvalue1234abcd"}}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      content,
+	})
+	err := client.Login()
+	if reflect.TypeOf(err) != reflect.TypeOf(expected) {
+		t.Errorf("Expected %T for %v: %v", expected, content, err)
+	}
+}
+
+func TestBotPasswordLogin(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	client.username = "myuser@backup"
+	client.clientLogin = true
+	setupLoginResponses(server)
+	err := client.Login()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	requests := server.Requests()
+	<-requests
+	request := <-requests
+	if request.Method != httpmock.PostMethod || request.Url != "http://wiki.example.org/api.php?action=login&format=json" || request.Body != "lgname=myuser%40backup&lgpassword=mypass&lgtoken=tokenvalue1234abcd%2B%5C" {
+		t.Errorf("Bot passwords should use action=login: %v", request)
+	}
+}
+
+func TestClientLogin(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	client.clientLogin = true
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"tokens":{"logintoken":"tokenvalue1234abcd"}}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"clientlogin":{"status":"PASS","username":"Myuser"}}`,
+	})
+	err := client.Login()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	requests := server.Requests()
+	<-requests
+	request := <-requests
+	if request.Method != httpmock.PostMethod || request.Url != "http://wiki.example.org/api.php?action=clientlogin&format=json" || request.Body != "loginreturnurl=http%3A%2F%2Fwiki.example.org%2Fapi.php&logintoken=tokenvalue1234abcd&password=mypass&username=myuser" {
+		t.Errorf("Bad clientlogin call: %v", request)
+	}
+}
+
+func TestClientLoginErrors(t *testing.T) {
+	assertClientLoginError(t, `{"clientlogin":{"status":"FAIL","message":"Incorrect password entered.","messagecode":"wrongpassword"}}`, &WrongPasswordError{})
+	assertClientLoginError(t, `{"clientlogin":{"status":"FAIL","message":"Too many recent login attempts.","messagecode":"login-throttled"}}`, &ThrottledError{})
+	assertClientLoginError(t, `{"clientlogin":{"status":"UI","message":"Enter a verification code","messagecode":"oathauth-auth-ui"}}`, &UnsupportedLoginError{})
+	assertClientLoginError(t, `{"clientlogin":{"status":"FAIL","message":"Blocked","messagecode":"login-userblocked"}}`, &LoginError{})
+}
+
+func assertClientLoginError(t *testing.T, content string, expected error) {
+	client, server := setup()
+	defer server.Close()
+	client.clientLogin = true
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"tokens":{"logintoken":"tokenvalue1234abcd"}}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      content,
+	})
+	err := client.Login()
+	if reflect.TypeOf(err) != reflect.TypeOf(expected) {
+		t.Errorf("Expected %T for %v: %v", expected, content, err)
+	}
+}
+
 func TestListWhenUnauthenticated(t *testing.T) {
 	client, server := setup()
 	defer server.Close()
@@ -115,7 +323,7 @@ func TestListTitles(t *testing.T) {
 	requests := server.Requests()
 	checkLoginCalls(t, requests)
 	request := <-requests
-	if request.Method != httpmock.GetMethod || request.Url != "http://wiki.example.org/api.php?format=json&action=query&list=allpages&aplimit=max" {
+	if request.Method != httpmock.GetMethod || request.Url != "http://wiki.example.org/api.php?action=query&aplimit=max&apnamespace=0&continue=&format=json&list=allpages" {
 		t.Errorf("Bad call: %v", request)
 	}
 	if len(requests) != 0 {
@@ -124,6 +332,102 @@ func TestListTitles(t *testing.T) {
 
 }
 
+func TestListTitlesContinuation(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"continue":{"apcontinue":"Second_article","continue":"-||"},"query":{"allpages":[{"title":"First article"}]}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"continue":{"apcontinue":"Third_article","continue":"-||"},"query":{"allpages":[{"title":"Second article"}]}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"allpages":[{"title":"Third article"}]}}`,
+	})
+	titles, err := client.ListArticleTitles()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(titles) != 3 || titles[0] != "First article" || titles[1] != "Second article" || titles[2] != "Third article" {
+		t.Errorf("Wrong titles: %v", titles)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	for _, expected := range []string{
+		"http://wiki.example.org/api.php?action=query&aplimit=max&apnamespace=0&continue=&format=json&list=allpages",
+		"http://wiki.example.org/api.php?action=query&apcontinue=Second_article&aplimit=max&apnamespace=0&continue=-%7C%7C&format=json&list=allpages",
+		"http://wiki.example.org/api.php?action=query&apcontinue=Third_article&aplimit=max&apnamespace=0&continue=-%7C%7C&format=json&list=allpages",
+	} {
+		request := <-requests
+		if request.Method != httpmock.GetMethod || request.Url != expected {
+			t.Errorf("Bad call: %v", request)
+		}
+	}
+	if len(requests) != 0 {
+		t.Errorf("Found extra requests: %v", len(requests))
+	}
+}
+
+func TestListTitlesContinuationReplacesKeys(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"continue":{"rvcontinue":"12|34","continue":"||"},"query":{"allpages":[{"title":"First article"}]}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"continue":{"apcontinue":"Second","continue":"-||"},"query":{}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":""}`,
+	})
+	titles, err := client.ListArticleTitles()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(titles) != 1 || titles[0] != "First article" {
+		t.Errorf("Wrong titles: %v", titles)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	<-requests
+	<-requests
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&apcontinue=Second&aplimit=max&apnamespace=0&continue=-%7C%7C&format=json&list=allpages" {
+		t.Errorf("Stale continue values were sent: %v", request)
+	}
+}
+
+func TestListTitlesContinuationFailure(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"continue":{"apcontinue":"Second_article","continue":"-||"},"query":{"allpages":[{"title":"First article"}]}}`,
+	})
+	_, err := client.ListArticleTitles()
+	if err == nil {
+		t.Errorf("Should have failed when a later batch failed")
+	}
+}
+
 func TestDownloadArticle(t *testing.T) {
 	client, server := setup()
 	defer server.Close()
@@ -160,3 +464,310 @@ func TestDownloadArticle(t *testing.T) {
 	}
 
 }
+
+func TestListNamespaces(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{"namespaces":{
+			"-1":{"id":-1,"case":"first-letter","canonical":"Special","*":"Special"},
+			"0":{"id":0,"case":"first-letter","content":"","*":""},
+			"10":{"id":10,"case":"first-letter","canonical":"Template","*":"Vorlage"},
+			"100":{"id":100,"case":"first-letter","content":"","*":"Recipe"}}}}`,
+	})
+	namespaces, err := client.ListNamespaces()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	expected := []Namespace{
+		{ID: -1, Name: "Special", Canonical: "Special", Case: "first-letter"},
+		{ID: 0, Content: true, Case: "first-letter"},
+		{ID: 10, Name: "Vorlage", Canonical: "Template", Case: "first-letter"},
+		{ID: 100, Name: "Recipe", Content: true, Case: "first-letter"},
+	}
+	if !reflect.DeepEqual(namespaces, expected) {
+		t.Errorf("Wrong namespaces: %v", namespaces)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	request := <-requests
+	if request.Method != httpmock.GetMethod || request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&meta=siteinfo&siprop=namespaces" {
+		t.Errorf("Bad call: %v", request)
+	}
+	if len(requests) != 0 {
+		t.Errorf("Found extra requests: %v", len(requests))
+	}
+}
+
+func TestListTitlesInNamespaces(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"allpages":[{"pageid":1,"ns":0,"title":"Home"}]}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"allpages":[{"pageid":2,"ns":10,"title":"Template:Box"},{"pageid":3,"ns":10,"title":"Template:Note"}]}}`,
+	})
+	titles, err := client.ListTitles([]int{0, 10})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	expected := []Title{
+		{Namespace: 0, Title: "Home", PageID: 1},
+		{Namespace: 10, Title: "Template:Box", PageID: 2},
+		{Namespace: 10, Title: "Template:Note", PageID: 3},
+	}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("Wrong titles: %v", titles)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	for _, expected := range []string{
+		"http://wiki.example.org/api.php?action=query&aplimit=max&apnamespace=0&continue=&format=json&list=allpages",
+		"http://wiki.example.org/api.php?action=query&aplimit=max&apnamespace=10&continue=&format=json&list=allpages",
+	} {
+		request := <-requests
+		if request.Method != httpmock.GetMethod || request.Url != expected {
+			t.Errorf("Bad call: %v", request)
+		}
+	}
+	if len(requests) != 0 {
+		t.Errorf("Found extra requests: %v", len(requests))
+	}
+}
+
+func TestDownloadMissingArticle(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 404,
+		ContentType:  "text/x-wiki",
+	})
+	_, err := client.GetArticle("Missing Page")
+	if err == nil {
+		t.Errorf("Should have failed on a missing article")
+	}
+}
+
+func TestAnonymous(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	client.username = ""
+	client.password = ""
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"allpages":[{"title":"Public article"}]}}`,
+	})
+	titles, err := client.ListArticleTitles()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(titles) != 1 || titles[0] != "Public article" {
+		t.Errorf("Wrong titles: %v", titles)
+	}
+
+	requests := server.Requests()
+	request := <-requests
+	if request.Method != httpmock.GetMethod || request.Url != "http://wiki.example.org/api.php?action=query&aplimit=max&apnamespace=0&continue=&format=json&list=allpages" {
+		t.Errorf("Should not have logged in: %v", request)
+	}
+	if len(requests) != 0 {
+		t.Errorf("Found extra requests: %v", len(requests))
+	}
+}
+
+func TestApiErrorEnvelope(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json; charset=utf-8",
+		Content:      `{"error":{"code":"readapidenied","info":"You need read permission to use this module.","*":"See api.php for usage."}}`,
+	})
+	_, err := client.ListArticleTitles()
+	apiError, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("Expected an api error: %v", err)
+	}
+	if apiError.Code != "readapidenied" || apiError.Info != "You need read permission to use this module." {
+		t.Errorf("Wrong api error: %v", apiError)
+	}
+}
+
+func TestApiWarnings(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"warnings":{"main":{"*":"Unrecognized parameter: foo."}},"batchcomplete":"","query":{"allpages":[{"title":"First article"}]}}`,
+	})
+	titles, err := client.ListArticleTitles()
+	if err != nil {
+		t.Errorf("Warnings should not fail the call: %v", err)
+	}
+	if len(titles) != 1 || titles[0] != "First article" {
+		t.Errorf("Wrong titles: %v", titles)
+	}
+}
+
+func TestApiBadStatus(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 503,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"allpages":[]}}`,
+	})
+	_, err := client.ListArticleTitles()
+	httpError, ok := err.(*HTTPError)
+	if !ok {
+		t.Fatalf("Expected an http error: %v", err)
+	}
+	if httpError.StatusCode != 503 {
+		t.Errorf("Wrong status: %v", httpError)
+	}
+}
+
+func TestApiBadContentType(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "text/html; charset=utf-8",
+		Content:      `<html><body>Please log in</body></html>`,
+	})
+	_, err := client.ListArticleTitles()
+	if _, ok := err.(*ContentTypeError); !ok {
+		t.Errorf("Expected a content type error: %v", err)
+	}
+}
+
+func TestLoginApiError(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"tokens":{"logintoken":"tokenvalue1234abcd"}}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"error":{"code":"badtoken","info":"Invalid CSRF token."}}`,
+	})
+	err := client.Login()
+	if apiError, ok := err.(*APIError); !ok || apiError.Code != "badtoken" {
+		t.Errorf("Expected a badtoken api error: %v", err)
+	}
+}
+
+func TestDownloadArticleServerError(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 500,
+		ContentType:  "text/html",
+		Content:      `<html><body>Internal error</body></html>`,
+	})
+	article, err := client.GetArticle("Home Page")
+	if _, ok := err.(*HTTPError); !ok {
+		t.Errorf("Expected an http error instead of the article <%v>: %v", article, err)
+	}
+}
+
+func TestDownloadArticleHtml(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "text/html",
+		Content:      `<html><body>Login required</body></html>`,
+	})
+	article, err := client.GetArticle("Home Page")
+	if _, ok := err.(*ContentTypeError); !ok {
+		t.Errorf("Expected a content type error instead of the article <%v>: %v", article, err)
+	}
+}
+
+func TestCancelledLoginIsRetried(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.LoginContext(ctx); err != context.Canceled {
+		t.Errorf("Should have been cancelled: %v", err)
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("Should not have made any requests")
+	}
+
+	setupLoginResponses(server)
+	if err := client.Login(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	checkLoginCalls(t, server.Requests())
+}
+
+func TestCancelledList(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	if err := client.Login(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.ListTitlesContext(ctx, []int{0})
+	if err == nil || ctx.Err() == nil {
+		t.Errorf("Should have been cancelled: %v", err)
+	}
+}
+
+func TestConcurrentRequestsLoginOnce(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	const articles = 5
+	for i := 0; i < articles; i++ {
+		server.QueueResponse(httpmock.Response{
+			ResponseCode: 200,
+			ContentType:  "application/wikitext",
+			Content:      "Article text",
+		})
+	}
+	errs := make(chan error)
+	for i := 0; i < articles; i++ {
+		go func() {
+			_, err := client.GetArticle("Home Page")
+			errs <- err
+		}()
+	}
+	for i := 0; i < articles; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	checkLoginCalls(t, server.Requests())
+	if len(server.Requests()) != articles {
+		t.Errorf("Should have logged in once and fetched every article: %v", len(server.Requests()))
+	}
+}
@@ -0,0 +1,95 @@
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Queries longer than this are POSTed instead
+const maxGetQueryLength = 2000
+
+// Run an action=query request, following the continue block in each response until the server stops returning one.
+// The query object of every batch is handed to handle in the order it was received
+func (c *client) queryAll(ctx context.Context, params url.Values, handle func(query json.RawMessage) error) error {
+	_, err := c.runQuery(ctx, params, handle)
+	return err
+}
+
+// Run an action=query request like queryAll, also asking for the server's clock. Returns the time of the first batch,
+// which is before anything in the responses was read
+func (c *client) queryAllAt(ctx context.Context, params url.Values, handle func(query json.RawMessage) error) (time.Time, error) {
+	values := make(url.Values)
+	for key, value := range params {
+		values[key] = value
+	}
+	values.Set("curtimestamp", "")
+	return c.runQuery(ctx, values, handle)
+}
+
+// Follow the continue blocks of a query, returning the server's clock from the first batch if it was asked for
+func (c *client) runQuery(ctx context.Context, params url.Values, handle func(query json.RawMessage) error) (time.Time, error) {
+	type result struct {
+		Query        json.RawMessage   `json:"query"`
+		Continue     map[string]string `json:"continue"`
+		CurTimestamp time.Time         `json:"curtimestamp"`
+	}
+	values := make(url.Values)
+	for key, value := range params {
+		values[key] = value
+	}
+	values.Set("format", "json")
+	values.Set("action", "query")
+	values.Set("continue", "")
+	if c.maxLag > 0 {
+		values.Set("maxlag", strconv.Itoa(c.maxLag))
+	}
+	var continueKeys []string
+	var started time.Time
+	for batch := 1; ; batch++ {
+		glog.V(1).Infof("Requesting batch %d", batch)
+		var res *http.Response
+		var err error
+		if query := values.Encode(); len(query) > maxGetQueryLength {
+			// Long lists of titles don't fit in a url that every server and proxy will accept
+			res, err = c.postForm(ctx, c.apiUrl(), values)
+		} else {
+			res, err = c.get(ctx, fmt.Sprintf("%s?%s", c.apiUrl(), query))
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		var response result
+		err = c.decodeApi(res, &response)
+		res.Body.Close()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if batch == 1 {
+			started = response.CurTimestamp
+		}
+		if response.Query != nil {
+			if err = handle(response.Query); err != nil {
+				return time.Time{}, err
+			}
+		}
+		if len(response.Continue) == 0 {
+			return started, nil
+		}
+		// Every value from the previous continue block has to be replaced, as later blocks may omit some keys
+		for _, key := range continueKeys {
+			values.Del(key)
+		}
+		continueKeys = continueKeys[:0]
+		for key, value := range response.Continue {
+			values.Set(key, value)
+			continueKeys = append(continueKeys, key)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package mediawiki
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// A change to save to a page, creating it if it doesn't exist unless NoCreate is set
+type Edit struct {
+	Title          string
+	Text           string // The whole new content of the page
+	Summary        string
+	Minor          bool
+	Bot            bool      // Keep the edit out of recent changes, for accounts with the bot right
+	BaseTimestamp  time.Time // When the revision the edit is based on was saved, so later edits cause a conflict
+	StartTimestamp time.Time // When the page was read, so that deleting it since is reported as a conflict
+	CreateOnly     bool      // Fail if the page already exists
+	NoCreate       bool      // Fail if the page doesn't exist
+}
+
+// What saving an edit did
+type EditResult struct {
+	PageID        int
+	Title         string
+	OldRevisionID int  // Zero if the edit created the page
+	NewRevisionID int  // Zero if nothing changed
+	NoChange      bool // Whether the page already had this content, so no revision was saved
+}
+
+// Save an edit with action=edit, fetching a CSRF token first
+func (c *client) Edit(edit Edit) (EditResult, error) {
+	return c.EditContext(context.Background(), edit)
+}
+
+// Save an edit with action=edit, fetching a CSRF token first. The token is kept for later edits, and fetched again if
+// the wiki no longer accepts it
+func (c *client) EditContext(ctx context.Context, edit Edit) (EditResult, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return EditResult{}, err
+	}
+	glog.V(1).Infof("Editing %s", edit.Title)
+	result, err := c.edit(ctx, edit)
+	if apiErr, ok := err.(*APIError); ok && apiErr.Code == "badtoken" {
+		// Tokens expire along with the session
+		c.tokenLock.Lock()
+		c.csrfToken = ""
+		c.tokenLock.Unlock()
+		result, err = c.edit(ctx, edit)
+	}
+	return result, err
+}
+
+func (c *client) edit(ctx context.Context, edit Edit) (EditResult, error) {
+	type editResult struct {
+		Result   string  `json:"result"`
+		PageID   int     `json:"pageid"`
+		Title    string  `json:"title"`
+		OldRevID int     `json:"oldrevid"`
+		NewRevID int     `json:"newrevid"`
+		NoChange *string `json:"nochange"`
+	}
+	type editResponse struct {
+		Edit *editResult `json:"edit"`
+	}
+	token, err := c.editToken(ctx)
+	if err != nil {
+		return EditResult{}, err
+	}
+	sum := md5.Sum([]byte(edit.Text))
+	values := make(url.Values)
+	values.Set("title", edit.Title)
+	values.Set("text", edit.Text)
+	values.Set("summary", edit.Summary)
+	// Lets the wiki reject text that was mangled on the way
+	values.Set("md5", hex.EncodeToString(sum[:]))
+	if edit.Minor {
+		values.Set("minor", "1")
+	}
+	if edit.Bot {
+		values.Set("bot", "1")
+	}
+	if !edit.BaseTimestamp.IsZero() {
+		values.Set("basetimestamp", edit.BaseTimestamp.UTC().Format(time.RFC3339))
+	}
+	if !edit.StartTimestamp.IsZero() {
+		values.Set("starttimestamp", edit.StartTimestamp.UTC().Format(time.RFC3339))
+	}
+	if edit.CreateOnly {
+		values.Set("createonly", "1")
+	}
+	if edit.NoCreate {
+		values.Set("nocreate", "1")
+	}
+	if c.username != "" {
+		// Never save the edit anonymously if the session was lost
+		values.Set("assert", "user")
+	}
+	values.Set("token", token)
+	res, err := c.postForm(ctx, c.actionUrl("edit"), values)
+	if err != nil {
+		return EditResult{}, err
+	}
+	defer res.Body.Close()
+	var response editResponse
+	if err = c.decodeApi(res, &response); err != nil {
+		return EditResult{}, err
+	}
+	if response.Edit == nil {
+		return EditResult{}, errors.New("Missing edit object from response")
+	}
+	if response.Edit.Result != "Success" {
+		return EditResult{}, &EditError{Title: edit.Title, Result: response.Edit.Result}
+	}
+	return EditResult{
+		PageID:        response.Edit.PageID,
+		Title:         response.Edit.Title,
+		OldRevisionID: response.Edit.OldRevID,
+		NewRevisionID: response.Edit.NewRevID,
+		NoChange:      response.Edit.NoChange != nil,
+	}, nil
+}
+
+// The CSRF token that edits need, fetched on first use
+func (c *client) editToken(ctx context.Context) (string, error) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+	if c.csrfToken != "" {
+		return c.csrfToken, nil
+	}
+	type tokens struct {
+		CSRFToken string `json:"csrftoken"`
+	}
+	type query struct {
+		Tokens tokens `json:"tokens"`
+	}
+	params := make(url.Values)
+	params.Set("meta", "tokens")
+	params.Set("type", "csrf")
+	err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+		var response query
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return err
+		}
+		c.csrfToken = response.Tokens.CSRFToken
+		return nil
+	})
+	if err == nil && c.csrfToken == "" {
+		err = errors.New("Missing CSRF token from response")
+	}
+	return c.csrfToken, err
+}
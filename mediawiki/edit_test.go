@@ -0,0 +1,172 @@
+package mediawiki
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stevearm/mediawiki-export/httpmock"
+)
+
+func queueCSRFToken(server *httpmock.Server, token string) {
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"tokens":{"csrftoken":"` + token + `"}}}`,
+	})
+}
+
+func checkEditCall(t *testing.T, request httpmock.Request, token string) url.Values {
+	if request.Method != httpmock.PostMethod || request.Url != "http://wiki.example.org/api.php?action=edit&format=json" {
+		t.Errorf("Bad edit call: %v", request)
+	}
+	values, err := url.ParseQuery(request.Body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if values.Get("token") != token {
+		t.Errorf("Wrong token: %v", request.Body)
+	}
+	return values
+}
+
+func TestEdit(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueCSRFToken(server, `abc123+\\`)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"edit":{"result":"Success","pageid":12,"title":"Recipe:Cake","contentmodel":"wikitext","oldrevid":0,
+			"newrevid":34,"newtimestamp":"2020-01-01T00:00:00Z","new":""}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"edit":{"result":"Success","pageid":12,"title":"Recipe:Cake","contentmodel":"wikitext","nochange":""}}`,
+	})
+	edit := Edit{Title: "Recipe:Cake", Text: "Flour & eggs", Summary: "Restored", Bot: true}
+	result, err := client.Edit(edit)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != (EditResult{PageID: 12, Title: "Recipe:Cake", NewRevisionID: 34}) {
+		t.Errorf("Wrong result: %+v", result)
+	}
+	// The token is kept for the next edit
+	result, err = client.Edit(edit)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.NoChange {
+		t.Errorf("Wrong result: %+v", result)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&meta=tokens&type=csrf" {
+		t.Errorf("Bad token call: %v", request)
+	}
+	values := checkEditCall(t, <-requests, `abc123+\`)
+	expected := url.Values{
+		"title":   {"Recipe:Cake"},
+		"text":    {"Flour & eggs"},
+		"summary": {"Restored"},
+		"md5":     {"4e30057db02eaf00873ec26773465050"},
+		"bot":     {"1"},
+		"assert":  {"user"},
+		"token":   {`abc123+\`},
+	}
+	for key := range expected {
+		if values.Get(key) != expected.Get(key) {
+			t.Errorf("Wrong %s: %v", key, values)
+		}
+	}
+	for _, key := range []string{"basetimestamp", "starttimestamp", "createonly", "nocreate"} {
+		if _, found := values[key]; found {
+			t.Errorf("Unexpected %s: %v", key, values)
+		}
+	}
+	checkEditCall(t, <-requests, `abc123+\`)
+}
+
+func TestEditConflictChecks(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueCSRFToken(server, "token")
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"error":{"code":"editconflict","info":"Edit conflict."}}`,
+	})
+	edit := Edit{
+		Title:          "Recipe:Cake",
+		Text:           "Flour & eggs",
+		BaseTimestamp:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		StartTimestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*60*60)),
+		NoCreate:       true,
+	}
+	if _, err := client.Edit(edit); err == nil {
+		t.Errorf("Edit conflict not reported")
+	}
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	<-requests
+	values := checkEditCall(t, <-requests, "token")
+	if values.Get("basetimestamp") != "2020-01-01T00:00:00Z" || values.Get("starttimestamp") != "2020-01-02T08:04:05Z" || values.Get("nocreate") != "1" {
+		t.Errorf("Wrong conflict checks: %v", values)
+	}
+	if _, found := values["createonly"]; found {
+		t.Errorf("Unexpected createonly: %v", values)
+	}
+}
+
+func TestEditWithExpiredToken(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueCSRFToken(server, "old")
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"error":{"code":"badtoken","info":"Invalid CSRF token."}}`,
+	})
+	queueCSRFToken(server, "new")
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"edit":{"result":"Success","pageid":12,"title":"Home","oldrevid":33,"newrevid":34}}`,
+	})
+	result, err := client.Edit(Edit{Title: "Home", Text: "Welcome"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.OldRevisionID != 33 || result.NewRevisionID != 34 {
+		t.Errorf("Wrong result: %+v", result)
+	}
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	<-requests
+	checkEditCall(t, <-requests, "old")
+	<-requests
+	checkEditCall(t, <-requests, "new")
+}
+
+func TestEditFailure(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueCSRFToken(server, "token")
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"edit":{"result":"Failure","captcha":{"type":"image","id":"1"}}}`,
+	})
+	_, err := client.Edit(Edit{Title: "Home", Text: "Welcome"})
+	if err == nil || err.Error() != "Edit of Home failed: Failure" {
+		t.Errorf("Wrong error: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package mediawiki
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// The wiki rejected the username or password
+type WrongPasswordError struct {
+	Result string
+	Reason string
+}
+
+func (e *WrongPasswordError) Error() string {
+	return fmt.Sprintf("Wrong username or password (%s): %s", e.Result, e.Reason)
+}
+
+// The wiki refused the login because of too many recent attempts
+type ThrottledError struct {
+	Wait   int // Seconds to wait before trying again, or zero if unknown
+	Reason string
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Wait > 0 {
+		return fmt.Sprintf("Login throttled, try again in %d seconds: %s", e.Wait, e.Reason)
+	}
+	return fmt.Sprintf("Login throttled: %s", e.Reason)
+}
+
+// The wiki does not allow this account to log in with the method used, or needs extra steps that cannot be automated
+type UnsupportedLoginError struct {
+	Result string
+	Reason string
+}
+
+func (e *UnsupportedLoginError) Error() string {
+	return fmt.Sprintf("Login method not supported (%s): %s. Try a bot password, or toggling clientlogin", e.Result, e.Reason)
+}
+
+// Any other failed login
+type LoginError struct {
+	Result string
+	Reason string
+}
+
+func (e *LoginError) Error() string {
+	return fmt.Sprintf("Login failed (%s): %s", e.Result, e.Reason)
+}
+
+// An error envelope returned by the api, such as {"error":{"code":"readapidenied","info":"..."}}
+type APIError struct {
+	Code string `json:"code"`
+	Info string `json:"info"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error %s: %s", e.Code, e.Info)
+}
+
+// Whether the error goes away by itself, such as maxlag. These are retried according to the client's retry policy
+func (e *APIError) Temporary() bool {
+	_, found := temporaryApiErrors[e.Code]
+	return found
+}
+
+// The server answered with something other than 200 OK
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Url        string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("Unexpected HTTP status %s from %s", e.Status, e.Url)
+}
+
+// Whether the status means the server is overloaded or briefly broken. These are retried according to the client's
+// retry policy
+func (e *HTTPError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// The server answered with content that is not what the call expects, such as an HTML error page
+type ContentTypeError struct {
+	ContentType string
+	Url         string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("Unexpected content type %s from %s", e.ContentType, e.Url)
+}
+
+// A downloaded file does not match the size or SHA-1 the wiki has for it, so it was changed or cut short on the way
+type ChecksumError struct {
+	Name     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("Downloaded %s has %s instead of %s", e.Name, e.Actual, e.Expected)
+}
+
+// The wiki refused an edit without an api error, such as when a CAPTCHA or an abuse filter stopped it
+type EditError struct {
+	Title  string
+	Result string
+}
+
+func (e *EditError) Error() string {
+	return fmt.Sprintf("Edit of %s failed: %s", e.Title, e.Result)
+}
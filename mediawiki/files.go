@@ -0,0 +1,222 @@
+package mediawiki
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// The current version of an uploaded file
+type File struct {
+	Name      string // Without the namespace, such as "Diagram.png"
+	Title     string // The title of its description page, such as "File:Diagram.png"
+	URL       string // Where to download it from
+	Size      int64  // In bytes
+	SHA1      string // In hex
+	MIME      string
+	User      string // Who uploaded this version
+	Timestamp time.Time
+}
+
+// What the api says about a file, as returned by both list=allimages and prop=imageinfo
+type fileInfo struct {
+	Name      string    `json:"name"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
+	Size      int64     `json:"size"`
+	SHA1      string    `json:"sha1"`
+	MIME      string    `json:"mime"`
+	User      string    `json:"user"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+const fileInfoProps = "timestamp|user|url|size|sha1|mime"
+
+func (c *client) newFile(info fileInfo) File {
+	file := File{
+		Name:      info.Name,
+		Title:     info.Title,
+		URL:       c.resolveUrl(info.URL),
+		Size:      info.Size,
+		SHA1:      info.SHA1,
+		MIME:      info.MIME,
+		User:      info.User,
+		Timestamp: info.Timestamp,
+	}
+	// Older wikis leave out the title from allimages, and the name from imageinfo
+	if file.Name == "" {
+		if colon := strings.Index(file.Title, ":"); colon >= 0 {
+			file.Name = file.Title[colon+1:]
+		}
+	}
+	if file.Title == "" {
+		file.Title = "File:" + file.Name
+	}
+	return file
+}
+
+// Urls of uploads are often protocol or host relative, such as //upload.example.org/a/ab/Diagram.png
+func (c *client) resolveUrl(fileUrl string) string {
+	base, err := url.Parse(c.apiUrl())
+	if err != nil {
+		return fileUrl
+	}
+	resolved, err := base.Parse(fileUrl)
+	if err != nil {
+		return fileUrl
+	}
+	return resolved.String()
+}
+
+// List every file uploaded to the wiki, with the details of its current version
+func (c *client) ListFiles() ([]File, error) {
+	return c.ListFilesContext(context.Background())
+}
+
+// List every file uploaded to the wiki, with the details of its current version
+func (c *client) ListFilesContext(ctx context.Context) ([]File, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return nil, err
+	}
+	type query struct {
+		AllImages []fileInfo `json:"allimages"`
+	}
+	glog.Info("Listing files")
+	params := make(url.Values)
+	params.Set("list", "allimages")
+	params.Set("aiprop", fileInfoProps)
+	params.Set("ailimit", "max")
+	var files []File
+	err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+		var response query
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return err
+		}
+		for _, info := range response.AllImages {
+			files = append(files, c.newFile(info))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Get the current version of the files with the given titles (such as "File:Diagram.png"), in the same order. Titles
+// without an uploaded file are left out
+func (c *client) GetFiles(titles []string) ([]File, error) {
+	return c.GetFilesContext(context.Background(), titles)
+}
+
+// Get the current version of the files with the given titles (such as "File:Diagram.png"), in the same order. Titles
+// without an uploaded file are left out
+func (c *client) GetFilesContext(ctx context.Context, titles []string) ([]File, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return nil, err
+	}
+	batchSize, err := c.pageBatchSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	type page struct {
+		Title     string     `json:"title"`
+		ImageInfo []fileInfo `json:"imageinfo"`
+	}
+	type query struct {
+		Normalized []struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		} `json:"normalized"`
+		Pages map[string]page `json:"pages"`
+	}
+	var files []File
+	for start := 0; start < len(titles); start += batchSize {
+		end := start + batchSize
+		if end > len(titles) {
+			end = len(titles)
+		}
+		params := make(url.Values)
+		params.Set("prop", "imageinfo")
+		params.Set("titles", strings.Join(titles[start:end], "|"))
+		params.Set("iiprop", fileInfoProps)
+		found := make(map[string]File)
+		normalized := make(map[string]string)
+		err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+			var response query
+			if err := json.Unmarshal(raw, &response); err != nil {
+				return err
+			}
+			for _, n := range response.Normalized {
+				normalized[n.From] = n.To
+			}
+			for _, p := range response.Pages {
+				if len(p.ImageInfo) > 0 {
+					info := p.ImageInfo[0]
+					info.Title = p.Title
+					found[p.Title] = c.newFile(info)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, title := range titles[start:end] {
+			if to, ok := normalized[title]; ok {
+				title = to
+			}
+			if file, ok := found[title]; ok {
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// Download the current version of a file into w, checking it against the size and SHA-1 the wiki has for it. As
+// the download is streamed, w may have been partly written when this fails
+func (c *client) DownloadFile(file File, w io.Writer) error {
+	return c.DownloadFileContext(context.Background(), file, w)
+}
+
+// Download the current version of a file into w, checking it against the size and SHA-1 the wiki has for it. As
+// the download is streamed, w may have been partly written when this fails
+func (c *client) DownloadFileContext(ctx context.Context, file File, w io.Writer) error {
+	if err := c.LoginContext(ctx); err != nil {
+		return err
+	}
+	glog.V(1).Infof("Downloading %s", file.Name)
+	res, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", file.URL, nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// Any content type is fine, as files can be HTML themselves
+	if res.StatusCode != http.StatusOK {
+		return &HTTPError{StatusCode: res.StatusCode, Status: res.Status, Url: c.redact(file.URL)}
+	}
+	hash := sha1.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), res.Body)
+	if err != nil {
+		return fmt.Errorf("Cannot download %s: %v", file.Name, err)
+	}
+	if size != file.Size {
+		return &ChecksumError{Name: file.Name, Expected: fmt.Sprintf("%d bytes", file.Size), Actual: fmt.Sprintf("%d bytes", size)}
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); file.SHA1 != "" && !strings.EqualFold(actual, file.SHA1) {
+		return &ChecksumError{Name: file.Name, Expected: "SHA-1 " + file.SHA1, Actual: "SHA-1 " + actual}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package mediawiki
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stevearm/mediawiki-export/httpmock"
+)
+
+func TestListFiles(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"continue":{"aicontinue":"Plan.pdf","continue":"-||"},"query":{"allimages":[
+			{"name":"Diagram.png","timestamp":"2020-01-01T00:00:00Z","user":"Alice","url":"//upload.example.org/a/ab/Diagram.png","size":5,"sha1":"f7ff9e8b7bb2e09b70935a5d785e0cc5d9d0abf0","mime":"image/png","ns":6,"title":"File:Diagram.png"}]}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{"allimages":[
+			{"name":"Plan.pdf","timestamp":"2020-01-02T00:00:00Z","user":"Bob","url":"/images/c/cd/Plan.pdf","size":2048,"sha1":"cd","mime":"application/pdf"}]}}`,
+	})
+	files, err := client.ListFiles()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []File{
+		{Name: "Diagram.png", Title: "File:Diagram.png", URL: "http://upload.example.org/a/ab/Diagram.png", Size: 5, SHA1: "f7ff9e8b7bb2e09b70935a5d785e0cc5d9d0abf0", MIME: "image/png", User: "Alice", Timestamp: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "Plan.pdf", Title: "File:Plan.pdf", URL: "http://wiki.example.org/images/c/cd/Plan.pdf", Size: 2048, SHA1: "cd", MIME: "application/pdf", User: "Bob", Timestamp: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	if len(files) != len(expected) || files[0] != expected[0] || files[1] != expected[1] {
+		t.Errorf("Wrong files: %+v", files)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&ailimit=max&aiprop=timestamp%7Cuser%7Curl%7Csize%7Csha1%7Cmime&continue=&format=json&list=allimages" {
+		t.Errorf("Bad allimages call: %v", request)
+	}
+	request = <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&aicontinue=Plan.pdf&ailimit=max&aiprop=timestamp%7Cuser%7Curl%7Csize%7Csha1%7Cmime&continue=-%7C%7C&format=json&list=allimages" {
+		t.Errorf("Bad continued allimages call: %v", request)
+	}
+}
+
+func TestGetFiles(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueUserInfo(server, `"read"`)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{
+			"normalized":[{"from":"File:diagram.png","to":"File:Diagram.png"}],
+			"pages":{
+				"-1":{"ns":6,"title":"File:Nothing.png","missing":"","imagerepository":""},
+				"7":{"pageid":7,"ns":6,"title":"File:Diagram.png","imagerepository":"local","imageinfo":[
+					{"timestamp":"2020-01-01T00:00:00Z","user":"Alice","size":5,"url":"http://wiki.example.org/images/a/ab/Diagram.png","sha1":"f7ff9e8b7bb2e09b70935a5d785e0cc5d9d0abf0","mime":"image/png"}]}
+			}}}`,
+	})
+	files, err := client.GetFiles([]string{"File:Nothing.png", "File:diagram.png"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "Diagram.png" || files[0].Title != "File:Diagram.png" || files[0].Size != 5 {
+		t.Errorf("Wrong files: %+v", files)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	<-requests
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&iiprop=timestamp%7Cuser%7Curl%7Csize%7Csha1%7Cmime&prop=imageinfo&titles=File%3ANothing.png%7CFile%3Adiagram.png" {
+		t.Errorf("Bad imageinfo call: %v", request)
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{ResponseCode: 200, ContentType: "image/png", Content: "Hello"})
+	server.QueueResponse(httpmock.Response{ResponseCode: 200, ContentType: "image/png", Content: "Hallo"})
+	server.QueueResponse(httpmock.Response{ResponseCode: 200, ContentType: "image/png", Content: "Hell"})
+	file := File{Name: "Diagram.png", URL: "http://wiki.example.org/images/a/ab/Diagram.png", Size: 5, SHA1: "f7ff9e8b7bb2e09b70935a5d785e0cc5d9d0abf0"}
+
+	var content bytes.Buffer
+	if err := client.DownloadFile(file, &content); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content.String() != "Hello" {
+		t.Errorf("Wrong content: %s", content.String())
+	}
+	err := client.DownloadFile(file, &bytes.Buffer{})
+	if _, ok := err.(*ChecksumError); !ok || err.Error() != "Downloaded Diagram.png has SHA-1 59d9a6df06b9f610f7db8e036896ed03662d168f instead of SHA-1 f7ff9e8b7bb2e09b70935a5d785e0cc5d9d0abf0" {
+		t.Errorf("Expected a checksum error: %v", err)
+	}
+	err = client.DownloadFile(file, &bytes.Buffer{})
+	if err == nil || err.Error() != "Downloaded Diagram.png has 4 bytes instead of 5 bytes" {
+		t.Errorf("Expected a size error: %v", err)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	request := <-requests
+	if request.Method != httpmock.GetMethod || request.Url != file.URL {
+		t.Errorf("Bad download: %v", request)
+	}
+}
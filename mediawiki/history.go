@@ -0,0 +1,118 @@
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// A single revision of a page, as fetched by GetHistory
+type Revision struct {
+	ID           int
+	ParentID     int // Zero for the revision that created the page
+	User         string
+	UserID       int // Zero for anonymous edits and hidden users
+	Comment      string
+	Minor        bool
+	Timestamp    time.Time
+	Size         int // In bytes
+	SHA1         string
+	ContentModel string
+	Content      string
+}
+
+// Get every revision of a page, oldest first, with its content
+func (c *client) GetHistory(title string) ([]Revision, error) {
+	return c.GetHistoryContext(context.Background(), title)
+}
+
+// Get every revision of a page, oldest first, with its content
+func (c *client) GetHistoryContext(ctx context.Context, title string) ([]Revision, error) {
+	return c.GetHistoryAfterContext(ctx, title, 0)
+}
+
+// Get the revisions of a page saved after the given one, oldest first, with their content. The given revision has to
+// exist and belong to the page. Zero gets every revision
+func (c *client) GetHistoryAfter(title string, revisionID int) ([]Revision, error) {
+	return c.GetHistoryAfterContext(context.Background(), title, revisionID)
+}
+
+// Get the revisions of a page saved after the given one, oldest first, with their content. The given revision has to
+// exist and belong to the page. Zero gets every revision
+func (c *client) GetHistoryAfterContext(ctx context.Context, title string, revisionID int) ([]Revision, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return nil, err
+	}
+	type revision struct {
+		RevID     int       `json:"revid"`
+		ParentID  int       `json:"parentid"`
+		User      string    `json:"user"`
+		UserID    int       `json:"userid"`
+		Comment   string    `json:"comment"`
+		Minor     *string   `json:"minor"`
+		Timestamp time.Time `json:"timestamp"`
+		Size      int       `json:"size"`
+		SHA1      string    `json:"sha1"`
+		revisionContent
+	}
+	type page struct {
+		Missing   *string    `json:"missing"`
+		Invalid   *string    `json:"invalid"`
+		Revisions []revision `json:"revisions"`
+	}
+	type query struct {
+		Pages map[string]page `json:"pages"`
+	}
+	glog.V(1).Infof("Fetching history of %s", title)
+	params := make(url.Values)
+	params.Set("prop", "revisions")
+	params.Set("titles", title)
+	params.Set("rvprop", "ids|flags|timestamp|user|userid|comment|size|sha1|content|contentmodel")
+	params.Set("rvslots", "main")
+	params.Set("rvlimit", "max")
+	params.Set("rvdir", "newer")
+	if revisionID > 0 {
+		// The start revision is included, and skipped below
+		params.Set("rvstartid", strconv.Itoa(revisionID))
+	}
+	var revisions []Revision
+	err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+		var response query
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return err
+		}
+		for _, p := range response.Pages {
+			if p.Missing != nil || p.Invalid != nil {
+				return fmt.Errorf("Article not found: %s", title)
+			}
+			for _, rev := range p.Revisions {
+				if rev.RevID <= revisionID {
+					continue
+				}
+				result := Revision{
+					ID:        rev.RevID,
+					ParentID:  rev.ParentID,
+					User:      rev.User,
+					UserID:    rev.UserID,
+					Comment:   rev.Comment,
+					Minor:     rev.Minor != nil,
+					Timestamp: rev.Timestamp,
+					Size:      rev.Size,
+					SHA1:      rev.SHA1,
+				}
+				result.ContentModel, result.Content, _ = rev.main()
+				revisions = append(revisions, result)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
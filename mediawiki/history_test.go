@@ -0,0 +1,90 @@
+package mediawiki
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stevearm/mediawiki-export/httpmock"
+)
+
+func TestGetHistory(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"continue":{"rvcontinue":"20200102000000|11","continue":"||"},"query":{"pages":{"12":{"pageid":12,"ns":0,"title":"Home page","revisions":[
+			{"revid":10,"parentid":0,"user":"Alice","userid":3,"timestamp":"2020-01-01T00:00:00Z","size":5,"sha1":"aa","comment":"Created page","slots":{"main":{"contentmodel":"wikitext","*":"Hello"}}}]}}}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{"pages":{"12":{"pageid":12,"ns":0,"title":"Home page","revisions":[
+			{"revid":11,"parentid":10,"minor":"","user":"Bob","timestamp":"2020-01-02T00:00:00Z","size":13,"sha1":"bb","comment":"typo","slots":{"main":{"contentmodel":"wikitext","*":"Hello, world!"}}}]}}}}`,
+	})
+	revisions, err := client.GetHistory("Home page")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Revision{
+		{ID: 10, User: "Alice", UserID: 3, Comment: "Created page", Timestamp: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Size: 5, SHA1: "aa", ContentModel: "wikitext", Content: "Hello"},
+		{ID: 11, ParentID: 10, User: "Bob", Comment: "typo", Minor: true, Timestamp: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Size: 13, SHA1: "bb", ContentModel: "wikitext", Content: "Hello, world!"},
+	}
+	if len(revisions) != len(expected) || revisions[0] != expected[0] || revisions[1] != expected[1] {
+		t.Errorf("Wrong revisions: %+v", revisions)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&prop=revisions&rvdir=newer&rvlimit=max&rvprop=ids%7Cflags%7Ctimestamp%7Cuser%7Cuserid%7Ccomment%7Csize%7Csha1%7Ccontent%7Ccontentmodel&rvslots=main&titles=Home+page" {
+		t.Errorf("Bad history call: %v", request)
+	}
+	request = <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=%7C%7C&format=json&prop=revisions&rvcontinue=20200102000000%7C11&rvdir=newer&rvlimit=max&rvprop=ids%7Cflags%7Ctimestamp%7Cuser%7Cuserid%7Ccomment%7Csize%7Csha1%7Ccontent%7Ccontentmodel&rvslots=main&titles=Home+page" {
+		t.Errorf("Bad continued history call: %v", request)
+	}
+}
+
+func TestGetHistoryMissing(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"pages":{"-1":{"ns":0,"title":"Nothing","missing":""}}}}`,
+	})
+	_, err := client.GetHistory("Nothing")
+	if err == nil || err.Error() != "Article not found: Nothing" {
+		t.Errorf("Expected an error: %v", err)
+	}
+}
+
+func TestGetHistoryAfter(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{"pages":{"12":{"pageid":12,"ns":0,"title":"Home page","revisions":[
+			{"revid":10,"parentid":0,"user":"Alice","userid":3,"timestamp":"2020-01-01T00:00:00Z","size":5,"sha1":"aa","comment":"Created page","slots":{"main":{"contentmodel":"wikitext","*":"Hello"}}},
+			{"revid":11,"parentid":10,"user":"Bob","timestamp":"2020-01-02T00:00:00Z","size":13,"sha1":"bb","comment":"typo","slots":{"main":{"contentmodel":"wikitext","*":"Hello, world!"}}}]}}}}`,
+	})
+	revisions, err := client.GetHistoryAfter("Home page", 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(revisions) != 1 || revisions[0].ID != 11 || revisions[0].Content != "Hello, world!" {
+		t.Errorf("Wrong revisions: %+v", revisions)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&prop=revisions&rvdir=newer&rvlimit=max&rvprop=ids%7Cflags%7Ctimestamp%7Cuser%7Cuserid%7Ccomment%7Csize%7Csha1%7Ccontent%7Ccontentmodel&rvslots=main&rvstartid=10&titles=Home+page" {
+		t.Errorf("Bad history call: %v", request)
+	}
+}
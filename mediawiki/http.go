@@ -0,0 +1,91 @@
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// Matches the parameters of a url or form body whose values must never be logged
+var secretParamRegex = regexp.MustCompile(`(?i)\b(lgpassword|password|lgtoken|logintoken|token)=[^&\s]*`)
+
+// Make a GET request, logging it without any secrets
+func (c *client) get(ctx context.Context, requestUrl string) (*http.Response, error) {
+	glog.V(2).Infof("GET %s", c.redact(requestUrl))
+	return c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", requestUrl, nil)
+	})
+}
+
+// Make a POST request with a form body, logging it without any secrets
+func (c *client) postForm(ctx context.Context, requestUrl string, values url.Values) (*http.Response, error) {
+	glog.V(2).Infof("POST %s with %s", c.redact(requestUrl), c.redact(values.Encode()))
+	return c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", requestUrl, strings.NewReader(values.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+}
+
+// Strip secret parameters, and the password itself, from anything about to be logged
+func (c *client) redact(s string) string {
+	s = secretParamRegex.ReplaceAllString(s, "$1=REDACTED")
+	if c.password != "" {
+		s = strings.Replace(s, c.password, "REDACTED", -1)
+		s = strings.Replace(s, url.QueryEscape(c.password), "REDACTED", -1)
+	}
+	return s
+}
+
+// Make sure a response is a 200 OK, and unless it has one of the allowed content types, that it is not an HTML page
+func (c *client) checkResponse(res *http.Response, contentTypes ...string) error {
+	requestUrl := c.redact(res.Request.URL.String())
+	if res.StatusCode != http.StatusOK {
+		return &HTTPError{StatusCode: res.StatusCode, Status: res.Status, Url: requestUrl}
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	for _, contentType := range contentTypes {
+		if mediaType == contentType {
+			return nil
+		}
+	}
+	if len(contentTypes) > 0 || mediaType == "text/html" {
+		return &ContentTypeError{ContentType: res.Header.Get("Content-Type"), Url: requestUrl}
+	}
+	return nil
+}
+
+// Decode the JSON response of an api call into result. Fails on bad responses and error envelopes, and logs warnings
+func (c *client) decodeApi(res *http.Response, result interface{}) error {
+	if err := c.checkResponse(res, "application/json"); err != nil {
+		return err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		Error    *APIError                         `json:"error"`
+		Warnings map[string]map[string]interface{} `json:"warnings"`
+	}
+	// Not every response is an object (bad servers can return anything), so only trust the envelope if it parses
+	if json.Unmarshal(body, &envelope) == nil {
+		if envelope.Error != nil {
+			return envelope.Error
+		}
+		for module, warning := range envelope.Warnings {
+			glog.Warningf("API warning from %s: %v", module, warning["*"])
+		}
+	}
+	return json.Unmarshal(body, result)
+}
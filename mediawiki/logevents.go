@@ -0,0 +1,86 @@
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// An entry of one of the wiki's logs
+type LogEvent struct {
+	Type      string // The log, such as delete or move
+	Action    string // Such as delete, restore, move or move_redir
+	Namespace int
+	Title     string
+	PageID    int
+	Timestamp time.Time
+	Target    string // Where the page was moved to, for move events
+}
+
+// Get the events of the given log (such as delete or move) since the given time, oldest first
+func (c *client) LogEvents(logType string, since time.Time) ([]LogEvent, error) {
+	return c.LogEventsContext(context.Background(), logType, since)
+}
+
+// Get the events of the given log (such as delete or move) since the given time, oldest first
+func (c *client) LogEventsContext(ctx context.Context, logType string, since time.Time) ([]LogEvent, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return nil, err
+	}
+	type event struct {
+		Type      string    `json:"type"`
+		Action    string    `json:"action"`
+		Namespace int       `json:"ns"`
+		Title     string    `json:"title"`
+		PageID    int       `json:"pageid"`
+		Timestamp time.Time `json:"timestamp"`
+		Params    struct {
+			TargetTitle string `json:"target_title"`
+		} `json:"params"`
+		// Wikis older than 1.25 put the target of a move here instead
+		Move struct {
+			NewTitle string `json:"new_title"`
+		} `json:"move"`
+	}
+	type query struct {
+		LogEvents []event `json:"logevents"`
+	}
+	glog.Infof("Listing %s log since %s", logType, since.UTC().Format(time.RFC3339))
+	params := make(url.Values)
+	params.Set("list", "logevents")
+	params.Set("letype", logType)
+	params.Set("lestart", since.UTC().Format(time.RFC3339))
+	params.Set("ledir", "newer")
+	params.Set("leprop", "type|title|ids|timestamp|details")
+	params.Set("lelimit", "max")
+	var events []LogEvent
+	err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+		var response query
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return err
+		}
+		for _, e := range response.LogEvents {
+			target := e.Params.TargetTitle
+			if target == "" {
+				target = e.Move.NewTitle
+			}
+			events = append(events, LogEvent{
+				Type:      e.Type,
+				Action:    e.Action,
+				Namespace: e.Namespace,
+				Title:     e.Title,
+				PageID:    e.PageID,
+				Timestamp: e.Timestamp,
+				Target:    target,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
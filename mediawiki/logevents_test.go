@@ -0,0 +1,39 @@
+package mediawiki
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stevearm/mediawiki-export/httpmock"
+)
+
+func TestLogEvents(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{"logevents":[
+			{"logid":1,"ns":0,"title":"Old name","pageid":2,"logpage":2,"params":{"target_ns":0,"target_title":"New name"},"type":"move","action":"move","timestamp":"2020-06-01T03:30:00Z"},
+			{"logid":2,"ns":0,"title":"Older name","pageid":3,"logpage":3,"move":{"new_ns":0,"new_title":"Old style"},"type":"move","action":"move_redir","timestamp":"2020-06-01T03:40:00Z"}]}}`,
+	})
+	events, err := client.LogEvents("move", time.Date(2020, 6, 1, 3, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []LogEvent{
+		{Type: "move", Action: "move", Title: "Old name", PageID: 2, Timestamp: time.Date(2020, 6, 1, 3, 30, 0, 0, time.UTC), Target: "New name"},
+		{Type: "move", Action: "move_redir", Title: "Older name", PageID: 3, Timestamp: time.Date(2020, 6, 1, 3, 40, 0, 0, time.UTC), Target: "Old style"},
+	}
+	if len(events) != len(expected) || events[0] != expected[0] || events[1] != expected[1] {
+		t.Errorf("Wrong events: %+v", events)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&ledir=newer&lelimit=max&leprop=type%7Ctitle%7Cids%7Ctimestamp%7Cdetails&lestart=2020-06-01T03%3A00%3A00Z&letype=move&list=logevents" {
+		t.Errorf("Bad logevents call: %v", request)
+	}
+}
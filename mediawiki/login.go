@@ -0,0 +1,203 @@
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+func (c *client) login(ctx context.Context) error {
+	c.initHttpClient()
+	if c.username == "" {
+		glog.Info("No username given, staying anonymous")
+		return nil
+	}
+	glog.Info("Logging in")
+	token, err := c.loginToken(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case token == "":
+		// Wikis older than 1.27 do not hand out login tokens through meta=tokens
+		return c.legacyLogin(ctx)
+	case c.clientLogin && !isBotPassword(c.username):
+		return c.clientLoginWithToken(ctx, token)
+	}
+	return c.loginWithToken(ctx, token)
+}
+
+// Bot passwords are used with a username of the form "Account@botname"
+func isBotPassword(username string) bool {
+	return strings.Contains(username, "@")
+}
+
+// Fetch a login token, or return "" if the wiki does not support meta=tokens for logins
+func (c *client) loginToken(ctx context.Context) (string, error) {
+	type tokens struct {
+		LoginToken string `json:"logintoken"`
+	}
+	type query struct {
+		Tokens tokens `json:"tokens"`
+	}
+	params := make(url.Values)
+	params.Set("meta", "tokens")
+	params.Set("type", "login")
+	var token string
+	err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+		var response query
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return err
+		}
+		token = response.Tokens.LoginToken
+		return nil
+	})
+	return token, err
+}
+
+type loginResult struct {
+	Result string `json:"result"`
+	Reason string `json:"reason"`
+	Token  string `json:"token"`
+	Wait   int    `json:"wait"`
+}
+
+// Log in with action=login, which works for bot passwords and (on most wikis) main accounts
+func (c *client) loginWithToken(ctx context.Context, token string) error {
+	type loginResponse struct {
+		Login *loginResult `json:"login"`
+	}
+	values := make(url.Values)
+	values.Set("lgname", c.username)
+	values.Set("lgpassword", c.password)
+	values.Set("lgtoken", token)
+	loginUrl := c.actionUrl("login")
+	glog.V(1).Info("Making login HTTP call")
+	res, err := c.postForm(ctx, loginUrl, values)
+	glog.V(2).Info("Finished login HTTP call")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	var response loginResponse
+	if err = c.decodeApi(res, &response); err != nil {
+		return err
+	}
+	if response.Login == nil {
+		return errors.New("Missing login object from response")
+	}
+	return response.Login.err()
+}
+
+// Log in with action=clientlogin, the interactive login method for main accounts
+func (c *client) clientLoginWithToken(ctx context.Context, token string) error {
+	type clientLoginResult struct {
+		Status      string `json:"status"`
+		Message     string `json:"message"`
+		MessageCode string `json:"messagecode"`
+	}
+	type clientLoginResponse struct {
+		ClientLogin *clientLoginResult `json:"clientlogin"`
+	}
+	values := make(url.Values)
+	values.Set("username", c.username)
+	values.Set("password", c.password)
+	values.Set("logintoken", token)
+	values.Set("loginreturnurl", c.apiUrl())
+	loginUrl := c.actionUrl("clientlogin")
+	glog.V(1).Info("Making clientlogin HTTP call")
+	res, err := c.postForm(ctx, loginUrl, values)
+	glog.V(2).Info("Finished clientlogin HTTP call")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	var response clientLoginResponse
+	if err = c.decodeApi(res, &response); err != nil {
+		return err
+	}
+	result := response.ClientLogin
+	if result == nil {
+		return errors.New("Missing clientlogin object from response")
+	}
+	switch {
+	case result.Status == "PASS":
+		return nil
+	case result.MessageCode == "login-throttled":
+		return &ThrottledError{Reason: result.Message}
+	case result.Status == "FAIL" && strings.Contains(result.MessageCode, "password"):
+		return &WrongPasswordError{Result: result.MessageCode, Reason: result.Message}
+	case result.Status == "UI" || result.Status == "REDIRECT":
+		// Further steps such as two-factor authentication need a person to complete them
+		return &UnsupportedLoginError{Result: result.Status, Reason: result.Message}
+	}
+	return &LoginError{Result: result.Status, Reason: result.Message}
+}
+
+// Log in with the NeedToken handshake of action=login, for wikis older than 1.27
+func (c *client) legacyLogin(ctx context.Context) error {
+	type loginResponse struct {
+		Login *loginResult `json:"login"`
+	}
+
+	// Make the first call to get a token
+	glog.V(1).Info("Making 1/2 HTTP calls")
+	values := make(url.Values)
+	values.Set("lgname", c.username)
+	values.Set("lgpassword", c.password)
+	loginUrl := c.actionUrl("login")
+	res, err := c.postForm(ctx, loginUrl, values)
+	glog.V(2).Info("Finished 1/2 HTTP calls")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	var response loginResponse
+	if err = c.decodeApi(res, &response); err != nil {
+		return err
+	}
+	if response.Login == nil {
+		return errors.New("Missing login object from response")
+	}
+	if response.Login.Result != "NeedToken" {
+		return response.Login.err()
+	}
+
+	// Do the same call, this time passing back the token
+	values.Set("lgtoken", response.Login.Token)
+	glog.V(1).Info("Making 2/2 HTTP calls")
+	res, err = c.postForm(ctx, loginUrl, values)
+	glog.V(2).Info("Finished 2/2 HTTP calls")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	response = loginResponse{}
+	if err = c.decodeApi(res, &response); err != nil {
+		return err
+	}
+	if response.Login == nil {
+		return errors.New("Missing login object from response")
+	}
+	return response.Login.err()
+}
+
+// Turn an action=login result into the matching error, or nil if the login succeeded
+func (r *loginResult) err() error {
+	switch r.Result {
+	case "Success":
+		return nil
+	case "WrongPass", "WrongPluginPass", "NotExists", "EmptyPass", "Illegal", "Failed":
+		return &WrongPasswordError{Result: r.Result, Reason: r.Reason}
+	case "Throttled":
+		return &ThrottledError{Wait: r.Wait, Reason: r.Reason}
+	case "Aborted", "NeedToken":
+		// Aborted is returned when logging into a main account with action=login where it is no longer supported
+		return &UnsupportedLoginError{Result: r.Result, Reason: r.Reason}
+	}
+	return &LoginError{Result: r.Result, Reason: r.Reason}
+}
@@ -0,0 +1,124 @@
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/golang/glog"
+)
+
+// A namespace of the wiki, as reported by siteinfo
+type Namespace struct {
+	ID        int
+	Name      string // Local name, which prefixes the titles of its pages. Empty for the main namespace
+	Canonical string // Canonical (English) name. Empty for the main namespace
+	Content   bool   // Whether this is a content namespace
+	Case      string // Either first-letter, when the first letter of titles is always upper case, or case-sensitive
+}
+
+// A page title, along with the namespace it belongs to
+type Title struct {
+	Namespace int
+	Title     string
+	PageID    int
+}
+
+// Get every namespace the wiki defines, sorted by ID
+func (c *client) ListNamespaces() ([]Namespace, error) {
+	return c.ListNamespacesContext(context.Background())
+}
+
+// Get every namespace the wiki defines, sorted by ID
+func (c *client) ListNamespacesContext(ctx context.Context) ([]Namespace, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return nil, err
+	}
+	glog.Info("Listing namespaces")
+	type namespace struct {
+		ID        int     `json:"id"`
+		Name      string  `json:"*"`
+		Canonical string  `json:"canonical"`
+		Content   *string `json:"content"`
+		Case      string  `json:"case"`
+	}
+	type query struct {
+		Namespaces map[string]namespace `json:"namespaces"`
+	}
+	params := make(url.Values)
+	params.Set("meta", "siteinfo")
+	params.Set("siprop", "namespaces")
+	var namespaces []Namespace
+	err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+		var response query
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return err
+		}
+		for _, ns := range response.Namespaces {
+			namespaces = append(namespaces, Namespace{
+				ID:        ns.ID,
+				Name:      ns.Name,
+				Canonical: ns.Canonical,
+				Content:   ns.Content != nil,
+				Case:      ns.Case,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byID(namespaces))
+	return namespaces, nil
+}
+
+// Get a list of all the pages in the given namespaces
+func (c *client) ListTitles(namespaces []int) ([]Title, error) {
+	return c.ListTitlesContext(context.Background(), namespaces)
+}
+
+// Get a list of all the pages in the given namespaces
+func (c *client) ListTitlesContext(ctx context.Context, namespaces []int) ([]Title, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return nil, err
+	}
+	type page struct {
+		Namespace int    `json:"ns"`
+		Title     string `json:"title"`
+		PageID    int    `json:"pageid"`
+	}
+	type query struct {
+		AllPages []page `json:"allpages"`
+	}
+	var titles []Title
+	// allpages only accepts a single namespace per query
+	for _, namespace := range namespaces {
+		glog.Infof("Listing pages in namespace %d", namespace)
+		params := make(url.Values)
+		params.Set("list", "allpages")
+		params.Set("aplimit", "max")
+		params.Set("apnamespace", strconv.Itoa(namespace))
+		err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+			var response query
+			if err := json.Unmarshal(raw, &response); err != nil {
+				return err
+			}
+			for _, page := range response.AllPages {
+				titles = append(titles, Title{Namespace: page.Namespace, Title: page.Title, PageID: page.PageID})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return titles, nil
+}
+
+type byID []Namespace
+
+func (n byID) Len() int           { return len(n) }
+func (n byID) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n byID) Less(i, j int) bool { return n[i].ID < n[j].ID }
@@ -0,0 +1,320 @@
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// The latest revision of a page, as fetched by GetPages
+type Page struct {
+	Requested     string // The title as it was asked for, before normalisation and redirects
+	Title         string // The title the wiki knows the page by
+	Namespace     int
+	PageID        int    // Zero if the page is missing or invalid
+	Missing       bool   // Whether no page exists with this title
+	Invalid       bool   // Whether the title can never exist, such as one containing [ or ]
+	InvalidReason string // Why the title is invalid
+	RedirectedTo  string // Set if the requested page was a redirect that was followed
+	Interwiki     string // The interwiki prefix if the title belongs to another wiki, in which case it is also Missing
+	RevisionID    int
+	User          string    // Who saved the revision. Empty if it has been hidden
+	UserID        int       // Zero for anonymous edits and hidden users
+	Timestamp     time.Time // When the revision was saved
+	ContentModel  string    // Such as wikitext, css or json
+	SHA1          string    // Hex sha1 of the content, as reported by the wiki
+	Content       string    // Raw wikitext of the main slot
+	ContentHidden bool      // Whether the content was hidden by an administrator or lost, leaving Content empty
+	Fetched       time.Time // The wiki's clock when the page was read, for detecting edits made since
+}
+
+// How many pages to ask for in one query. Accounts with the apihighlimits right (bots and admins) get more
+const (
+	pageBatchSize     = 50
+	pageHighBatchSize = 500
+)
+
+// Get the content of the latest revision of many pages, using as few requests as possible. There is one Page for each
+// title, in the same order. Pages that are missing or have invalid titles are flagged rather than failing the whole
+// call. If followRedirects is set, redirect pages are replaced by the pages they point to
+func (c *client) GetPages(titles []string, followRedirects bool) ([]Page, error) {
+	return c.GetPagesContext(context.Background(), titles, followRedirects)
+}
+
+// Get the content of the latest revision of many pages, using as few requests as possible. There is one Page for each
+// title, in the same order. Pages that are missing or have invalid titles are flagged rather than failing the whole
+// call. If followRedirects is set, redirect pages are replaced by the pages they point to
+func (c *client) GetPagesContext(ctx context.Context, titles []string, followRedirects bool) ([]Page, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return nil, err
+	}
+	batchSize, err := c.pageBatchSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pages := make([]Page, 0, len(titles))
+	for start := 0; start < len(titles); start += batchSize {
+		end := start + batchSize
+		if end > len(titles) {
+			end = len(titles)
+		}
+		batch, err := c.getPageBatch(ctx, titles[start:end], followRedirects)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, batch...)
+	}
+	return pages, nil
+}
+
+// The content of a revision as prop=revisions gives it, which both pages and histories are fetched with
+type revisionContent struct {
+	Slots map[string]struct {
+		ContentModel string  `json:"contentmodel"`
+		Content      *string `json:"*"`
+	} `json:"slots"`
+	// Wikis older than 1.32 have no slots
+	ContentModel string  `json:"contentmodel"`
+	Content      *string `json:"*"`
+}
+
+// The content model and content of the main slot. Without content, the api flags it as hidden or missing instead
+func (r revisionContent) main() (string, string, bool) {
+	if main, ok := r.Slots["main"]; ok {
+		if main.Content == nil {
+			return main.ContentModel, "", false
+		}
+		return main.ContentModel, *main.Content, true
+	}
+	if r.Content != nil {
+		return r.ContentModel, *r.Content, true
+	}
+	return r.ContentModel, "", false
+}
+
+// Fetch a single batch of pages, following continuation when the content doesn't fit in one response
+func (c *client) getPageBatch(ctx context.Context, titles []string, followRedirects bool) ([]Page, error) {
+	type revision struct {
+		RevID     int       `json:"revid"`
+		User      string    `json:"user"`
+		UserID    int       `json:"userid"`
+		Timestamp time.Time `json:"timestamp"`
+		SHA1      string    `json:"sha1"`
+		revisionContent
+	}
+	type page struct {
+		PageID        int        `json:"pageid"`
+		Namespace     int        `json:"ns"`
+		Title         string     `json:"title"`
+		Missing       *string    `json:"missing"`
+		Invalid       *string    `json:"invalid"`
+		InvalidReason string     `json:"invalidreason"`
+		Revisions     []revision `json:"revisions"`
+	}
+	type mapping struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	type interwiki struct {
+		Title  string `json:"title"`
+		Prefix string `json:"iw"`
+	}
+	type query struct {
+		Normalized []mapping       `json:"normalized"`
+		Redirects  []mapping       `json:"redirects"`
+		Interwiki  []interwiki     `json:"interwiki"`
+		Pages      map[string]page `json:"pages"`
+	}
+	glog.V(1).Infof("Fetching %d pages", len(titles))
+	params := make(url.Values)
+	params.Set("prop", "revisions")
+	params.Set("rvprop", "content|ids|timestamp|sha1|user|userid|contentmodel")
+	params.Set("rvslots", "main")
+	params.Set("titles", strings.Join(titles, "|"))
+	if followRedirects {
+		params.Set("redirects", "")
+	}
+	normalized := make(map[string]string)
+	redirects := make(map[string]string)
+	found := make(map[string]Page)
+	fetched, err := c.queryAllAt(ctx, params, func(raw json.RawMessage) error {
+		var response query
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return err
+		}
+		for _, m := range response.Normalized {
+			normalized[m.From] = m.To
+		}
+		for _, m := range response.Redirects {
+			redirects[m.From] = m.To
+		}
+		// Titles on other wikis, whether asked for or redirected to, are listed apart from the pages
+		for _, iw := range response.Interwiki {
+			found[iw.Title] = Page{Title: iw.Title, Missing: true, Interwiki: iw.Prefix}
+		}
+		for _, p := range response.Pages {
+			result, seen := found[p.Title]
+			if !seen {
+				result = Page{
+					Title:         p.Title,
+					Namespace:     p.Namespace,
+					PageID:        p.PageID,
+					Missing:       p.Missing != nil,
+					Invalid:       p.Invalid != nil,
+					InvalidReason: p.InvalidReason,
+				}
+			}
+			// When content doesn't fit, a page comes back without revisions in one batch and with them in a later one
+			if len(p.Revisions) > 0 {
+				rev := p.Revisions[0]
+				result.RevisionID = rev.RevID
+				result.User = rev.User
+				result.UserID = rev.UserID
+				result.Timestamp = rev.Timestamp
+				result.SHA1 = rev.SHA1
+				var hasMain bool
+				result.ContentModel, result.Content, hasMain = rev.main()
+				result.ContentHidden = !hasMain
+			}
+			found[p.Title] = result
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	pages := make([]Page, len(titles))
+	for i, requested := range titles {
+		title := requested
+		if to, ok := normalized[title]; ok {
+			title = to
+		}
+		redirectedTo := ""
+		// Redirects can chain, but the wiki only resolves one level, and a loop must not hang us
+		for hops := 0; hops < 2; hops++ {
+			to, ok := redirects[title]
+			if !ok {
+				break
+			}
+			title = to
+			redirectedTo = to
+		}
+		result, ok := found[title]
+		if !ok {
+			return nil, fmt.Errorf("Page %s was missing from the response", requested)
+		}
+		if !result.Missing && !result.Invalid && result.RevisionID == 0 {
+			return nil, fmt.Errorf("Page %s came back without a revision", requested)
+		}
+		result.Requested = requested
+		result.RedirectedTo = redirectedTo
+		result.Fetched = fetched
+		pages[i] = result
+	}
+	return pages, nil
+}
+
+// Get the title each redirect among titles points to, keyed by the title as it was asked for. Titles that aren't
+// redirects are left out. No content is fetched, so it is cheaper than following redirects with GetPages
+func (c *client) GetRedirects(titles []string) (map[string]string, error) {
+	return c.GetRedirectsContext(context.Background(), titles)
+}
+
+// Get the title each redirect among titles points to, keyed by the title as it was asked for. Titles that aren't
+// redirects are left out. No content is fetched, so it is cheaper than following redirects with GetPages
+func (c *client) GetRedirectsContext(ctx context.Context, titles []string) (map[string]string, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return nil, err
+	}
+	batchSize, err := c.pageBatchSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	type mapping struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	type query struct {
+		Normalized []mapping `json:"normalized"`
+		Redirects  []mapping `json:"redirects"`
+	}
+	targets := make(map[string]string)
+	for start := 0; start < len(titles); start += batchSize {
+		end := start + batchSize
+		if end > len(titles) {
+			end = len(titles)
+		}
+		params := make(url.Values)
+		params.Set("titles", strings.Join(titles[start:end], "|"))
+		params.Set("redirects", "")
+		normalized := make(map[string]string)
+		redirects := make(map[string]string)
+		err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+			var response query
+			if err := json.Unmarshal(raw, &response); err != nil {
+				return err
+			}
+			for _, m := range response.Normalized {
+				normalized[m.From] = m.To
+			}
+			for _, m := range response.Redirects {
+				redirects[m.From] = m.To
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, requested := range titles[start:end] {
+			title := requested
+			if to, ok := normalized[title]; ok {
+				title = to
+			}
+			if to, ok := redirects[title]; ok {
+				targets[requested] = to
+			}
+		}
+	}
+	return targets, nil
+}
+
+// Find out how many pages can be fetched at once, asking the wiki about the account's rights the first time
+func (c *client) pageBatchSize(ctx context.Context) (int, error) {
+	c.limitsLock.Lock()
+	defer c.limitsLock.Unlock()
+	if c.batchSize > 0 {
+		return c.batchSize, nil
+	}
+	type query struct {
+		UserInfo struct {
+			Rights []string `json:"rights"`
+		} `json:"userinfo"`
+	}
+	params := make(url.Values)
+	params.Set("meta", "userinfo")
+	params.Set("uiprop", "rights")
+	batchSize := pageBatchSize
+	err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+		var response query
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return err
+		}
+		for _, right := range response.UserInfo.Rights {
+			if right == "apihighlimits" {
+				batchSize = pageHighBatchSize
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	glog.V(1).Infof("Fetching up to %d pages per request", batchSize)
+	c.batchSize = batchSize
+	return batchSize, nil
+}
@@ -0,0 +1,310 @@
+package mediawiki
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stevearm/mediawiki-export/httpmock"
+)
+
+func queueUserInfo(server *httpmock.Server, rights string) {
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"userinfo":{"id":1,"name":"Myuser","rights":[` + rights + `]}}}`,
+	})
+}
+
+func TestGetPages(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueUserInfo(server, `"read","edit"`)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","curtimestamp":"2020-06-07T08:09:10Z","query":{
+			"normalized":[{"from":"home page","to":"Home page"}],
+			"pages":{
+				"12":{"pageid":12,"ns":0,"title":"Home page","revisions":[{"revid":345,"parentid":300,"user":"Alice","userid":7,"timestamp":"2020-03-04T05:06:07Z","sha1":"0123abcd","slots":{"main":{"contentmodel":"wikitext","contentformat":"text/x-wiki","*":"Welcome"}}}]},
+				"-1":{"ns":0,"title":"No such page","missing":""},
+				"-2":{"title":"Bad[title]","invalidreason":"The requested page title contains invalid characters: \"[\".","invalid":""}
+			}}}`,
+	})
+	pages, err := client.GetPages([]string{"home page", "No such page", "Bad[title]"}, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("Wrong pages: %v", pages)
+	}
+	expected := Page{
+		Requested:    "home page",
+		Title:        "Home page",
+		PageID:       12,
+		RevisionID:   345,
+		User:         "Alice",
+		UserID:       7,
+		Timestamp:    time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC),
+		ContentModel: "wikitext",
+		SHA1:         "0123abcd",
+		Content:      "Welcome",
+		Fetched:      time.Date(2020, 6, 7, 8, 9, 10, 0, time.UTC),
+	}
+	if pages[0] != expected {
+		t.Errorf("Wrong page: %+v", pages[0])
+	}
+	if !pages[1].Missing || pages[1].Requested != "No such page" || pages[1].Content != "" {
+		t.Errorf("Page should be missing: %+v", pages[1])
+	}
+	if !pages[2].Invalid || pages[2].InvalidReason == "" {
+		t.Errorf("Page should be invalid: %+v", pages[2])
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&meta=userinfo&uiprop=rights" {
+		t.Errorf("Bad userinfo call: %v", request)
+	}
+	request = <-requests
+	if request.Method != httpmock.GetMethod || request.Url != "http://wiki.example.org/api.php?action=query&continue=&curtimestamp=&format=json&prop=revisions&rvprop=content%7Cids%7Ctimestamp%7Csha1%7Cuser%7Cuserid%7Ccontentmodel&rvslots=main&titles=home+page%7CNo+such+page%7CBad%5Btitle%5D" {
+		t.Errorf("Bad revisions call: %v", request)
+	}
+}
+
+func TestGetPagesFollowsRedirects(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueUserInfo(server, "")
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{
+			"normalized":[{"from":"old_name","to":"Old name"}],
+			"redirects":[{"from":"Old name","to":"New name"}],
+			"pages":{"7":{"pageid":7,"ns":0,"title":"New name","revisions":[{"revid":8,"timestamp":"2020-03-04T05:06:07Z","sha1":"ab","contentmodel":"wikitext","*":"Moved here"}]}}}}`,
+	})
+	pages, err := client.GetPages([]string{"old_name", "New name"}, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pages) != 2 || pages[0].Title != "New name" || pages[0].RedirectedTo != "New name" || pages[0].Content != "Moved here" {
+		t.Errorf("Should have followed the redirect: %+v", pages)
+	}
+	if pages[1].Title != "New name" || pages[1].RedirectedTo != "" || pages[1].Content != "Moved here" || pages[1].ContentModel != "wikitext" {
+		t.Errorf("Wrong page: %+v", pages[1])
+	}
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	<-requests
+	request := <-requests
+	values, _ := url.ParseQuery(request.Url[len("http://wiki.example.org/api.php?"):])
+	if _, found := values["redirects"]; !found {
+		t.Errorf("Should have asked to resolve redirects: %v", request)
+	}
+}
+
+func TestGetPagesInterwiki(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueUserInfo(server, "")
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{
+			"redirects":[{"from":"Elsewhere","to":"wikipedia:Somewhere","tointerwiki":"wikipedia"}],
+			"interwiki":[{"title":"wikipedia:Somewhere","iw":"wikipedia"},{"title":"meta:Main Page","iw":"meta"}],
+			"pages":{"7":{"pageid":7,"ns":0,"title":"Here","revisions":[{"revid":8,"timestamp":"2020-03-04T05:06:07Z","sha1":"ab","contentmodel":"wikitext","*":"Local"}]}}}}`,
+	})
+	pages, err := client.GetPages([]string{"Elsewhere", "meta:Main Page", "Here"}, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("Wrong number of pages: %+v", pages)
+	}
+	if !pages[0].Missing || pages[0].Interwiki != "wikipedia" || pages[0].Title != "wikipedia:Somewhere" || pages[0].RedirectedTo != "wikipedia:Somewhere" {
+		t.Errorf("Should have flagged the redirect to another wiki: %+v", pages[0])
+	}
+	if !pages[1].Missing || pages[1].Interwiki != "meta" || pages[1].Requested != "meta:Main Page" {
+		t.Errorf("Should have flagged the title on another wiki: %+v", pages[1])
+	}
+	if pages[2].Missing || pages[2].Interwiki != "" || pages[2].Content != "Local" {
+		t.Errorf("Wrong page: %+v", pages[2])
+	}
+}
+
+func TestGetRedirects(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueUserInfo(server, "")
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{
+			"normalized":[{"from":"old_name","to":"Old name"}],
+			"redirects":[{"from":"Old name","to":"New name"}],
+			"pages":{"7":{"pageid":7,"ns":0,"title":"New name"}}}}`,
+	})
+	targets, err := client.GetRedirects([]string{"old_name", "New name"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(targets) != 1 || targets["old_name"] != "New name" {
+		t.Errorf("Wrong redirects: %v", targets)
+	}
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	<-requests
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&redirects=&titles=old_name%7CNew+name" {
+		t.Errorf("Bad redirects call: %v", request)
+	}
+}
+
+func TestGetPagesContinues(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueUserInfo(server, "")
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"continue":{"rvcontinue":"2|20","continue":"||"},"query":{"pages":{
+			"1":{"pageid":1,"ns":0,"title":"First","revisions":[{"revid":10,"timestamp":"2020-01-01T00:00:00Z","sha1":"aa","slots":{"main":{"*":"One"}}}]},
+			"2":{"pageid":2,"ns":0,"title":"Second"}}}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{"pages":{
+			"1":{"pageid":1,"ns":0,"title":"First"},
+			"2":{"pageid":2,"ns":0,"title":"Second","revisions":[{"revid":20,"timestamp":"2020-01-02T00:00:00Z","sha1":"bb","slots":{"main":{"*":"Two"}}}]}}}}`,
+	})
+	pages, err := client.GetPages([]string{"First", "Second"}, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pages) != 2 || pages[0].Content != "One" || pages[0].RevisionID != 10 || pages[1].Content != "Two" || pages[1].RevisionID != 20 {
+		t.Errorf("Should have merged both batches: %+v", pages)
+	}
+}
+
+func TestGetPagesWithoutRevision(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueUserInfo(server, "")
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"pages":{"1":{"pageid":1,"ns":0,"title":"First"}}}}`,
+	})
+	_, err := client.GetPages([]string{"First"}, false)
+	if err == nil || err.Error() != "Page First came back without a revision" {
+		t.Errorf("Expected an error: %v", err)
+	}
+}
+
+func TestGetPagesBlankAndHidden(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueUserInfo(server, "")
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{"pages":{
+			"1":{"pageid":1,"ns":0,"title":"Blank","revisions":[{"revid":10,"slots":{"main":{"contentmodel":"wikitext","*":""}}}]},
+			"2":{"pageid":2,"ns":0,"title":"Hidden","revisions":[{"revid":20,"slots":{"main":{"contentmodel":"wikitext","texthidden":""}}}]}
+			}}}`,
+	})
+	pages, err := client.GetPages([]string{"Blank", "Hidden"}, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pages[0].ContentHidden || pages[0].Content != "" {
+		t.Errorf("Page should be blank: %+v", pages[0])
+	}
+	if !pages[1].ContentHidden {
+		t.Errorf("Page should be hidden: %+v", pages[1])
+	}
+}
+
+// Queue a response holding the given pages, named after their number
+func queuePages(server *httpmock.Server, first int, last int) {
+	content := `{"batchcomplete":"","query":{"pages":{`
+	for i := first; i <= last; i++ {
+		if i > first {
+			content += ","
+		}
+		content += fmt.Sprintf(`"%d":{"pageid":%d,"ns":0,"title":"Page %d","revisions":[{"revid":%d,"timestamp":"2020-01-01T00:00:00Z","sha1":"aa","slots":{"main":{"*":"Text"}}}]}`, i, i, i, i)
+	}
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      content + "}}}",
+	})
+}
+
+func pageTitles(count int) []string {
+	titles := make([]string, count)
+	for i := range titles {
+		titles[i] = fmt.Sprintf("Page %d", i+1)
+	}
+	return titles
+}
+
+func TestGetPagesBatches(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueUserInfo(server, `"read"`)
+	queuePages(server, 1, 50)
+	queuePages(server, 51, 60)
+	pages, err := client.GetPages(pageTitles(60), false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pages) != 60 || pages[59].Title != "Page 60" {
+		t.Errorf("Wrong pages: %v", len(pages))
+	}
+	if len(server.Requests()) != 5 {
+		t.Errorf("Should have fetched two batches of 50: %v", len(server.Requests()))
+	}
+}
+
+func TestGetPagesHighLimits(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	queueUserInfo(server, `"read","apihighlimits"`)
+	queuePages(server, 1, 300)
+	pages, err := client.GetPages(pageTitles(300), false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pages) != 300 {
+		t.Errorf("Wrong pages: %v", len(pages))
+	}
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	<-requests
+	request := <-requests
+	if len(requests) != 0 {
+		t.Errorf("Should have fetched a single batch: %v", len(requests))
+	}
+	if request.Method != httpmock.PostMethod || request.Url != "http://wiki.example.org/api.php" {
+		t.Errorf("Long title lists should be POSTed: %v", request)
+	}
+	values, _ := url.ParseQuery(request.Body)
+	if values.Get("action") != "query" || values.Get("titles") == "" {
+		t.Errorf("Bad revisions call: %v", request)
+	}
+}
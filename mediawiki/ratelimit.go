@@ -0,0 +1,68 @@
+package mediawiki
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A token bucket shared by every request a client makes, including retries. Safe for concurrent use
+type rateLimiter struct {
+	rate   float64 // Tokens added per second
+	burst  float64 // Most tokens the bucket holds, which is how many requests can go out back to back
+	now    func() time.Time
+	sleep  func(ctx context.Context, wait time.Duration) error
+	lock   sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// Create a limiter allowing rate requests per second on average, and up to burst at once. Returns nil (which never
+// waits) if rate is not positive
+func newRateLimiter(rate float64, burst int, sleep func(ctx context.Context, wait time.Duration) error) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		now:    time.Now,
+		sleep:  sleep,
+		tokens: float64(burst),
+	}
+}
+
+// Block until a request may be sent, or the context is cancelled
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	wait := l.reserve()
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	return l.sleep(ctx, wait)
+}
+
+// Take a token, going into debt if the bucket is empty, and return how long to wait until the debt is paid off.
+// Reserving up front keeps concurrent callers queued fairly without holding the lock while they sleep
+func (l *rateLimiter) reserve() time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
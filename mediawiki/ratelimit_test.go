@@ -0,0 +1,94 @@
+package mediawiki
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stevearm/mediawiki-export/httpmock"
+)
+
+// Set up a limiter on a fake clock that only moves when told to, recording how long it asks to wait
+func setupRateLimiter(rate float64, burst int) (*rateLimiter, *time.Time, *[]time.Duration) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var waits []time.Duration
+	limiter := newRateLimiter(rate, burst, func(ctx context.Context, wait time.Duration) error {
+		waits = append(waits, wait)
+		return nil
+	})
+	limiter.now = func() time.Time { return now }
+	return limiter, &now, &waits
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	limiter, _, waits := setupRateLimiter(2, 3)
+	for i := 0; i < 5; i++ {
+		if err := limiter.wait(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	// The first three go out at once, then each has to wait for the one before it
+	if len(*waits) != 2 || (*waits)[0] != 500*time.Millisecond || (*waits)[1] != time.Second {
+		t.Errorf("Wrong waits: %v", *waits)
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	limiter, now, waits := setupRateLimiter(1, 2)
+	limiter.wait(context.Background())
+	limiter.wait(context.Background())
+	*now = now.Add(time.Hour)
+	limiter.wait(context.Background())
+	limiter.wait(context.Background())
+	if len(*waits) != 0 {
+		t.Errorf("Should have refilled up to the burst: %v", *waits)
+	}
+	limiter.wait(context.Background())
+	if len(*waits) != 1 || (*waits)[0] != time.Second {
+		t.Errorf("Should not refill beyond the burst: %v", *waits)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := newRateLimiter(0, 5, sleepContext)
+	if limiter != nil {
+		t.Errorf("Should not limit without a rate: %v", limiter)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := limiter.wait(ctx); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	cancel()
+	if err := limiter.wait(ctx); err != context.Canceled {
+		t.Errorf("Should still notice cancellation: %v", err)
+	}
+}
+
+func TestRateLimitedRequests(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	limiter, _, waits := setupRateLimiter(10, 1)
+	client.limiter = limiter
+	client.userAgent = "mwexport-test/1.0 (ops@example.org)"
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/wikitext",
+		Content:      "Article text",
+	})
+	_, err := client.GetArticle("Home Page")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	// Token, login and article: only the first fits in the bucket
+	if len(*waits) != 2 {
+		t.Errorf("Every request should be rate limited: %v", *waits)
+	}
+	requests := server.Requests()
+	for i := 0; i < 3; i++ {
+		request := <-requests
+		if request.UserAgent != "mwexport-test/1.0 (ops@example.org)" {
+			t.Errorf("Wrong user agent: %v", request)
+		}
+	}
+}
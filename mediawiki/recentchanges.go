@@ -0,0 +1,88 @@
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// An entry of the wiki's recent changes
+type Change struct {
+	Type       string // edit, new or log
+	Namespace  int
+	Title      string
+	PageID     int
+	RevisionID int // Zero for log entries
+	Timestamp  time.Time
+	LogType    string // Such as delete or move, for log entries
+	LogAction  string // Such as delete, restore or move, for log entries
+}
+
+// Get the edits, page creations and log entries in the given namespaces since the given time, oldest first. Wikis
+// only keep recent changes for a limited time (90 days by default)
+func (c *client) RecentChanges(since time.Time, namespaces []int) ([]Change, error) {
+	return c.RecentChangesContext(context.Background(), since, namespaces)
+}
+
+// Get the edits, page creations and log entries in the given namespaces since the given time, oldest first. Wikis
+// only keep recent changes for a limited time (90 days by default)
+func (c *client) RecentChangesContext(ctx context.Context, since time.Time, namespaces []int) ([]Change, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return nil, err
+	}
+	type change struct {
+		Type      string    `json:"type"`
+		Namespace int       `json:"ns"`
+		Title     string    `json:"title"`
+		PageID    int       `json:"pageid"`
+		RevID     int       `json:"revid"`
+		Timestamp time.Time `json:"timestamp"`
+		LogType   string    `json:"logtype"`
+		LogAction string    `json:"logaction"`
+	}
+	type query struct {
+		RecentChanges []change `json:"recentchanges"`
+	}
+	glog.Infof("Listing changes since %s", since.UTC().Format(time.RFC3339))
+	ids := make([]string, len(namespaces))
+	for i, namespace := range namespaces {
+		ids[i] = strconv.Itoa(namespace)
+	}
+	params := make(url.Values)
+	params.Set("list", "recentchanges")
+	params.Set("rcstart", since.UTC().Format(time.RFC3339))
+	params.Set("rcdir", "newer")
+	params.Set("rcnamespace", strings.Join(ids, "|"))
+	params.Set("rcprop", "title|ids|timestamp|loginfo")
+	params.Set("rctype", "edit|new|log")
+	params.Set("rclimit", "max")
+	var changes []Change
+	err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+		var response query
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return err
+		}
+		for _, rc := range response.RecentChanges {
+			changes = append(changes, Change{
+				Type:       rc.Type,
+				Namespace:  rc.Namespace,
+				Title:      rc.Title,
+				PageID:     rc.PageID,
+				RevisionID: rc.RevID,
+				Timestamp:  rc.Timestamp,
+				LogType:    rc.LogType,
+				LogAction:  rc.LogAction,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
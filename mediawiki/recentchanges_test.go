@@ -0,0 +1,48 @@
+package mediawiki
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stevearm/mediawiki-export/httpmock"
+)
+
+func TestRecentChanges(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"continue":{"rccontinue":"20200601040000|12","continue":"-||"},"query":{"recentchanges":[
+			{"type":"edit","ns":0,"title":"Home page","pageid":12,"revid":345,"old_revid":300,"rcid":1,"timestamp":"2020-06-01T03:30:00Z"}]}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{"recentchanges":[
+			{"type":"log","ns":0,"title":"Old page","pageid":0,"revid":0,"old_revid":0,"rcid":2,"timestamp":"2020-06-01T04:00:00Z","logid":7,"logtype":"delete","logaction":"delete"}]}}`,
+	})
+	changes, err := client.RecentChanges(time.Date(2020, 6, 1, 3, 0, 0, 0, time.UTC), []int{0, 100})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Change{
+		{Type: "edit", Title: "Home page", PageID: 12, RevisionID: 345, Timestamp: time.Date(2020, 6, 1, 3, 30, 0, 0, time.UTC)},
+		{Type: "log", Title: "Old page", Timestamp: time.Date(2020, 6, 1, 4, 0, 0, 0, time.UTC), LogType: "delete", LogAction: "delete"},
+	}
+	if len(changes) != len(expected) || changes[0] != expected[0] || changes[1] != expected[1] {
+		t.Errorf("Wrong changes: %+v", changes)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&list=recentchanges&rcdir=newer&rclimit=max&rcnamespace=0%7C100&rcprop=title%7Cids%7Ctimestamp%7Cloginfo&rcstart=2020-06-01T03%3A00%3A00Z&rctype=edit%7Cnew%7Clog" {
+		t.Errorf("Bad recentchanges call: %v", request)
+	}
+	request = <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=-%7C%7C&format=json&list=recentchanges&rccontinue=20200601040000%7C12&rcdir=newer&rclimit=max&rcnamespace=0%7C100&rcprop=title%7Cids%7Ctimestamp%7Cloginfo&rcstart=2020-06-01T03%3A00%3A00Z&rctype=edit%7Cnew%7Clog" {
+		t.Errorf("Bad continued recentchanges call: %v", request)
+	}
+}
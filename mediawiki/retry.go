@@ -0,0 +1,152 @@
+package mediawiki
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// How requests that fail for temporary reasons are retried
+type RetryPolicy struct {
+	Attempts   int           // Total attempts for each request, including the first. Zero or one never retries
+	MinBackoff time.Duration // Wait before the first retry, doubled for every later one
+	MaxBackoff time.Duration // Longest wait between two attempts, unless the server asks for longer with Retry-After
+}
+
+// A policy suitable for long unattended exports
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:   5,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// How long to wait before the given retry (counting from 1). Jitter keeps concurrent clients from retrying in lockstep
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	wait := p.MinBackoff
+	for i := 1; i < retry && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// Api error codes, as sent in the MediaWiki-API-Error header, that go away by themselves
+var temporaryApiErrors = map[string]struct{}{
+	"maxlag":      {},
+	"ratelimited": {},
+	"readonly":    {},
+}
+
+// Whether a response should be retried, and how long the server asked us to wait (zero if it didn't say)
+func shouldRetry(res *http.Response) (bool, time.Duration) {
+	retryAfter := parseRetryAfter(res.Header.Get("Retry-After"))
+	if _, found := temporaryApiErrors[res.Header.Get("MediaWiki-API-Error")]; found {
+		return true, retryAfter
+	}
+	if (&HTTPError{StatusCode: res.StatusCode}).Temporary() {
+		return true, retryAfter
+	}
+	return false, 0
+}
+
+// Whether a request failed on the way for a reason that may go away, such as a timeout or a dropped connection.
+// Mistakes such as a wrong host name or an untrusted certificate fail the same way every time
+func temporaryNetworkError(err error) bool {
+	for _, dropped := range []error{syscall.ECONNRESET, syscall.ECONNABORTED, syscall.EPIPE, io.EOF, io.ErrUnexpectedEOF} {
+		if errors.Is(err, dropped) {
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && (netErr.Timeout() || netErr.Temporary())
+}
+
+// Parse a Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// Send a request, retrying temporary network errors, server errors and temporary api errors as the policy says
+func (c *client) do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		if c.userAgent != "" {
+			req.Header.Set("User-Agent", c.userAgent)
+		}
+		res, err := c.httpClient.Do(req)
+		if ctx.Err() != nil {
+			if res != nil {
+				res.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+		if attempt >= c.retry.Attempts {
+			return res, err
+		}
+		var retryAfter time.Duration
+		if err != nil {
+			if !temporaryNetworkError(err) {
+				return nil, err
+			}
+			glog.Warningf("Request failed, retrying: %v", err)
+		} else {
+			var retry bool
+			if retry, retryAfter = shouldRetry(res); !retry {
+				return res, nil
+			}
+			glog.Warningf("Got %s (%s), retrying", res.Status, res.Header.Get("MediaWiki-API-Error"))
+			res.Body.Close()
+		}
+		wait := c.retry.backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if err = c.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Wait for the given time, or until the context is cancelled
+func sleepContext(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
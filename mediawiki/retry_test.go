@@ -0,0 +1,267 @@
+package mediawiki
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stevearm/mediawiki-export/httpmock"
+)
+
+// Set up a client that retries, recording how long it waits instead of sleeping
+func setupRetries(attempts int) (*client, *httpmock.Server, *[]time.Duration) {
+	client, server := setup()
+	client.retry = RetryPolicy{Attempts: attempts, MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	var waits []time.Duration
+	client.sleep = func(ctx context.Context, wait time.Duration) error {
+		waits = append(waits, wait)
+		return ctx.Err()
+	}
+	return client, server, &waits
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	client, server, waits := setupRetries(4)
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{ResponseCode: 502, ContentType: "text/html", Content: "Bad gateway"})
+	server.QueueResponse(httpmock.Response{ResponseCode: 503, ContentType: "text/html", Content: "Unavailable"})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/wikitext",
+		Content:      "Article text",
+	})
+	article, err := client.GetArticle("Home Page")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if article != "Article text" {
+		t.Errorf("Incorrect article body: <%v>", article)
+	}
+	if len(*waits) != 2 {
+		t.Fatalf("Should have retried twice: %v", *waits)
+	}
+	if (*waits)[0] < 50*time.Millisecond || (*waits)[0] > 100*time.Millisecond || (*waits)[1] < 100*time.Millisecond || (*waits)[1] > 200*time.Millisecond {
+		t.Errorf("Backoff should double with jitter: %v", *waits)
+	}
+	if len(server.Requests()) != 5 {
+		t.Errorf("Wrong number of requests: %v", len(server.Requests()))
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	client, server, waits := setupRetries(3)
+	defer server.Close()
+	setupLoginResponses(server)
+	for i := 0; i < 3; i++ {
+		server.QueueResponse(httpmock.Response{ResponseCode: 500, ContentType: "text/html", Content: "Broken"})
+	}
+	_, err := client.GetArticle("Home Page")
+	httpError, ok := err.(*HTTPError)
+	if !ok || !httpError.Temporary() {
+		t.Errorf("Expected a temporary http error: %v", err)
+	}
+	if len(*waits) != 2 {
+		t.Errorf("Should have retried twice: %v", *waits)
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	client, server, waits := setupRetries(2)
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 429,
+		ContentType:  "text/plain",
+		Headers:      map[string]string{"Retry-After": "7"},
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"allpages":[{"title":"First article"}]}}`,
+	})
+	_, err := client.ListArticleTitles()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 7*time.Second {
+		t.Errorf("Should have waited as long as the server asked: %v", *waits)
+	}
+}
+
+func TestRetryMaxLag(t *testing.T) {
+	client, server, waits := setupRetries(3)
+	defer server.Close()
+	client.maxLag = 5
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Headers:      map[string]string{"Retry-After": "5", "MediaWiki-API-Error": "maxlag", "X-Database-Lag": "8"},
+		Content:      `{"error":{"code":"maxlag","info":"Waiting for 10.0.0.1: 8 seconds lagged.","host":"10.0.0.1","lag":8}}`,
+	})
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content:      `{"batchcomplete":"","query":{"allpages":[{"title":"First article"}]}}`,
+	})
+	titles, err := client.ListArticleTitles()
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(titles) != 1 {
+		t.Errorf("Wrong titles: %v", titles)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 5*time.Second {
+		t.Errorf("Should have backed off for maxlag: %v", *waits)
+	}
+
+	requests := server.Requests()
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&maxlag=5&meta=tokens&type=login" {
+		t.Errorf("Bad token call: %v", request)
+	}
+	request = <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=login&format=json&maxlag=5" {
+		t.Errorf("Bad login call: %v", request)
+	}
+	request = <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&aplimit=max&apnamespace=0&continue=&format=json&list=allpages&maxlag=5" {
+		t.Errorf("Bad list call: %v", request)
+	}
+}
+
+func TestNoRetryOnFatalErrors(t *testing.T) {
+	client, server, waits := setupRetries(3)
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Headers:      map[string]string{"MediaWiki-API-Error": "readapidenied"},
+		Content:      `{"error":{"code":"readapidenied","info":"You need read permission to use this module."}}`,
+	})
+	_, err := client.ListArticleTitles()
+	apiError, ok := err.(*APIError)
+	if !ok || apiError.Temporary() {
+		t.Errorf("Expected a fatal api error: %v", err)
+	}
+	server.QueueResponse(httpmock.Response{ResponseCode: 403, ContentType: "text/html", Content: "Forbidden"})
+	_, err = client.GetArticle("Home Page")
+	httpError, ok := err.(*HTTPError)
+	if !ok || httpError.Temporary() {
+		t.Errorf("Expected a fatal http error: %v", err)
+	}
+	if len(*waits) != 0 {
+		t.Errorf("Should not have retried: %v", *waits)
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	client, server, _ := setupRetries(5)
+	defer server.Close()
+	setupLoginResponses(server)
+	if err := client.Login(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	client.sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+	_, err := client.GetArticleContext(ctx, "Home Page")
+	if err != context.Canceled {
+		t.Errorf("Should have stopped retrying once cancelled: %v", err)
+	}
+}
+
+// Fails every request with the given error before it reaches the server
+type failingTransport struct {
+	err      error
+	attempts int
+}
+
+func (t *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.attempts++
+	return nil, t.err
+}
+
+func TestRetryNetworkErrors(t *testing.T) {
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	for _, test := range []struct {
+		err      error
+		attempts int
+	}{
+		{reset, 3},
+		{context.DeadlineExceeded, 3},
+		{io.ErrUnexpectedEOF, 3},
+		{x509.UnknownAuthorityError{}, 1},
+		{&net.DNSError{Err: "no such host", Name: "wiki.example.org", IsNotFound: true}, 1},
+	} {
+		client, server, _ := setupRetries(3)
+		transport := &failingTransport{err: test.err}
+		client.httpClient = &http.Client{Transport: transport}
+		client.username = ""
+		_, err := client.ListArticleTitles()
+		if err == nil || transport.attempts != test.attempts {
+			t.Errorf("Expected %d attempts for %v, got %d: %v", test.attempts, test.err, transport.attempts, err)
+		}
+		server.Close()
+	}
+}
+
+// Answers every request after running cancel, as if the context was cancelled while the response came in
+type cancellingTransport struct {
+	cancel func()
+	body   *closeRecorder
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func (t *cancellingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.cancel()
+	return &http.Response{StatusCode: 200, Header: make(http.Header), Body: t.body}, nil
+}
+
+func TestCancelledResponseIsClosed(t *testing.T) {
+	client, server, _ := setupRetries(3)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := &cancellingTransport{cancel: cancel, body: &closeRecorder{Reader: strings.NewReader("{}")}}
+	client.httpClient = &http.Client{Transport: transport}
+	res, err := client.do(ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, "http://wiki.example.org/api.php", nil)
+	})
+	if res != nil || err != context.Canceled || !transport.body.closed {
+		t.Errorf("Expected a closed body and no response: %v %v", res, err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if wait := parseRetryAfter("120"); wait != 2*time.Minute {
+		t.Errorf("Wrong wait: %v", wait)
+	}
+	if wait := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); wait < 59*time.Minute || wait > time.Hour {
+		t.Errorf("Wrong wait: %v", wait)
+	}
+	for _, header := range []string{"", "soon", "-5"} {
+		if wait := parseRetryAfter(header); wait != 0 {
+			t.Errorf("Wrong wait for %v: %v", header, wait)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package mediawiki
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// Where a wiki is served from
+type Site struct {
+	Scheme      string // Either http or https
+	Host        string // Hostname, without the port
+	Port        int    // Zero for the default port of the scheme
+	ScriptPath  string // Path that api.php and index.php live under, such as "/w". Empty when they are at the root
+	ArticlePath string // Optional path that pages are viewed under, with $1 standing for the title, such as "/wiki/$1"
+}
+
+func (s Site) baseUrl() string {
+	host := s.Host
+	if s.Port != 0 {
+		host = net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return fmt.Sprintf("%s://%s", s.Scheme, host)
+}
+
+// Get the location of api.php
+func (s Site) ApiUrl() string {
+	return fmt.Sprintf("%s%s/api.php", s.baseUrl(), s.ScriptPath)
+}
+
+// Get the location of index.php
+func (s Site) IndexUrl() string {
+	return fmt.Sprintf("%s%s/index.php", s.baseUrl(), s.ScriptPath)
+}
+
+// Get the location a page is viewed at in a browser
+func (s Site) ArticleUrl(title string) string {
+	name := strings.Replace(title, " ", "_", -1)
+	if s.ArticlePath == "" {
+		return fmt.Sprintf("%s?title=%s", s.IndexUrl(), url.QueryEscape(name))
+	}
+	return s.baseUrl() + strings.Replace(s.ArticlePath, "$1", url.PathEscape(name), 1)
+}
+
+// Parse the location of a wiki. This is either a bare host ("wiki.example.org" or "wiki.example.org:8080"), or a url
+// of the script path ("https://example.org/w"), of api.php or of index.php. Bare hosts are assumed to use http
+func ParseSite(location string) (Site, error) {
+	if !strings.Contains(location, "://") {
+		location = "http://" + location
+	}
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return Site{}, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return Site{}, fmt.Errorf("Unsupported scheme: %s", parsed.Scheme)
+	}
+	if parsed.Hostname() == "" {
+		return Site{}, fmt.Errorf("Missing host: %s", location)
+	}
+	site := Site{
+		Scheme: parsed.Scheme,
+		Host:   parsed.Hostname(),
+	}
+	if port := parsed.Port(); port != "" {
+		site.Port, err = strconv.Atoi(port)
+		if err != nil {
+			return Site{}, fmt.Errorf("Invalid port: %s", port)
+		}
+	}
+	scriptPath := strings.TrimSuffix(parsed.Path, "/")
+	scriptPath = strings.TrimSuffix(scriptPath, "/api.php")
+	scriptPath = strings.TrimSuffix(scriptPath, "/index.php")
+	site.ScriptPath = scriptPath
+	return site, nil
+}
+
+var linkRegex = regexp.MustCompile(`(?i)<link\s[^>]*>`)
+var editUriRegex = regexp.MustCompile(`(?i)\brel\s*=\s*["']?EditURI\b`)
+var hrefRegex = regexp.MustCompile(`(?i)\bhref\s*=\s*["']([^"']*)["']`)
+
+// Find a wiki from the url of any of its pages, using the EditURI link every MediaWiki page has to find api.php
+func DiscoverSite(location string, httpClient *http.Client) (Site, error) {
+	if !strings.Contains(location, "://") {
+		location = "http://" + location
+	}
+	pageUrl, err := url.Parse(location)
+	if err != nil {
+		return Site{}, err
+	}
+	if strings.HasSuffix(pageUrl.Path, "/api.php") {
+		return ParseSite(location)
+	}
+	glog.V(1).Infof("Looking for the EditURI link on %s", pageUrl)
+	res, err := httpClient.Get(pageUrl.String())
+	if err != nil {
+		return Site{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return Site{}, &HTTPError{StatusCode: res.StatusCode, Status: res.Status, Url: pageUrl.String()}
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return Site{}, err
+	}
+	for _, link := range linkRegex.FindAllString(string(body), -1) {
+		if !editUriRegex.MatchString(link) {
+			continue
+		}
+		href := hrefRegex.FindStringSubmatch(link)
+		if href == nil {
+			continue
+		}
+		apiUrl, err := res.Request.URL.Parse(href[1])
+		if err != nil {
+			return Site{}, err
+		}
+		apiUrl.RawQuery = ""
+		glog.V(1).Infof("Found api at %s", apiUrl)
+		return ParseSite(apiUrl.String())
+	}
+	return Site{}, fmt.Errorf("Could not find the api from %s. Pass the url of api.php instead", location)
+}
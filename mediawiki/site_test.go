@@ -0,0 +1,110 @@
+package mediawiki
+
+import (
+	"testing"
+
+	"github.com/stevearm/mediawiki-export/httpmock"
+)
+
+func TestParseSite(t *testing.T) {
+	assertParseSite(t, "wiki.example.org", Site{Scheme: "http", Host: "wiki.example.org"})
+	assertParseSite(t, "wiki.example.org:8080", Site{Scheme: "http", Host: "wiki.example.org", Port: 8080})
+	assertParseSite(t, "https://example.org/w", Site{Scheme: "https", Host: "example.org", ScriptPath: "/w"})
+	assertParseSite(t, "https://example.org/w/", Site{Scheme: "https", Host: "example.org", ScriptPath: "/w"})
+	assertParseSite(t, "https://example.org:8443/w/api.php", Site{Scheme: "https", Host: "example.org", Port: 8443, ScriptPath: "/w"})
+	assertParseSite(t, "http://example.org/mediawiki/index.php?title=Main", Site{Scheme: "http", Host: "example.org", ScriptPath: "/mediawiki"})
+	for _, location := range []string{"ftp://example.org", "http://", "http://example.org:port"} {
+		if site, err := ParseSite(location); err == nil {
+			t.Errorf("Parsing %v should have failed: %v", location, site)
+		}
+	}
+}
+
+func assertParseSite(t *testing.T, location string, expected Site) {
+	site, err := ParseSite(location)
+	if err != nil {
+		t.Errorf("Unexpected error parsing %v: %v", location, err)
+	}
+	if site != expected {
+		t.Errorf("Parsed %v into %v instead of %v", location, site, expected)
+	}
+}
+
+func TestSiteUrls(t *testing.T) {
+	site := Site{Scheme: "https", Host: "example.org", Port: 8443, ScriptPath: "/w"}
+	if site.ApiUrl() != "https://example.org:8443/w/api.php" {
+		t.Errorf("Wrong api url: %v", site.ApiUrl())
+	}
+	if site.IndexUrl() != "https://example.org:8443/w/index.php" {
+		t.Errorf("Wrong index url: %v", site.IndexUrl())
+	}
+	if site.ArticleUrl("Other's House") != "https://example.org:8443/w/index.php?title=Other%27s_House" {
+		t.Errorf("Wrong article url: %v", site.ArticleUrl("Other's House"))
+	}
+	site.ArticlePath = "/wiki/$1"
+	if site.ArticleUrl("Dogs & Cats") != "https://example.org:8443/wiki/Dogs_&_Cats" {
+		t.Errorf("Wrong article url: %v", site.ArticleUrl("Dogs & Cats"))
+	}
+	site = Site{Scheme: "http", Host: "wiki.example.org"}
+	if site.ApiUrl() != "http://wiki.example.org/api.php" {
+		t.Errorf("Wrong api url: %v", site.ApiUrl())
+	}
+}
+
+func TestDiscoverSite(t *testing.T) {
+	server := &httpmock.Server{}
+	httpClient := server.Init(httpmock.ErrorResponse())
+	defer server.Close()
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "text/html",
+		Content: `<!DOCTYPE html><html><head>
+<link rel="alternate" type="application/x-wiki" title="Edit" href="/w/index.php?title=Main_Page&amp;action=edit"/>
+<link rel="EditURI" type="application/rsd+xml" href="//example.org:8080/w/api.php?action=rsd"/>
+</head><body></body></html>`,
+	})
+	site, err := DiscoverSite("http://example.org:8080/wiki/Main_Page", httpClient)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if site != (Site{Scheme: "http", Host: "example.org", Port: 8080, ScriptPath: "/w"}) {
+		t.Errorf("Wrong site: %v", site)
+	}
+
+	requests := server.Requests()
+	request := <-requests
+	if request.Method != httpmock.GetMethod || request.Url != "http://example.org:8080/wiki/Main_Page" {
+		t.Errorf("Bad call: %v", request)
+	}
+}
+
+func TestDiscoverSiteFromApiUrl(t *testing.T) {
+	server := &httpmock.Server{}
+	httpClient := server.Init(httpmock.ErrorResponse())
+	defer server.Close()
+	site, err := DiscoverSite("https://example.org/w/api.php", httpClient)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if site != (Site{Scheme: "https", Host: "example.org", ScriptPath: "/w"}) {
+		t.Errorf("Wrong site: %v", site)
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("Should not have made any requests")
+	}
+}
+
+func TestDiscoverSiteWithoutLink(t *testing.T) {
+	server := &httpmock.Server{}
+	httpClient := server.Init(httpmock.ErrorResponse())
+	defer server.Close()
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "text/html",
+		Content:      `<html><head><title>Not a wiki</title></head></html>`,
+	})
+	_, err := DiscoverSite("wiki.example.org", httpClient)
+	if err == nil {
+		t.Errorf("Should have failed without an EditURI link")
+	}
+}
@@ -0,0 +1,62 @@
+package mediawiki
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/golang/glog"
+)
+
+// General information about a wiki, as reported by siteinfo
+type SiteInfo struct {
+	Name      string // Such as "Example Wiki"
+	DBName    string // The wiki ID, such as "examplewiki"
+	Base      string // The url of the main page
+	Generator string // The MediaWiki version, such as "MediaWiki 1.35.0"
+	Case      string // Either first-letter, when the first letter of titles is always upper case, or case-sensitive
+}
+
+// Get the name, version and such of the wiki
+func (c *client) GetSiteInfo() (SiteInfo, error) {
+	return c.GetSiteInfoContext(context.Background())
+}
+
+// Get the name, version and such of the wiki
+func (c *client) GetSiteInfoContext(ctx context.Context) (SiteInfo, error) {
+	if err := c.LoginContext(ctx); err != nil {
+		return SiteInfo{}, err
+	}
+	glog.V(1).Info("Fetching site information")
+	type general struct {
+		SiteName  string `json:"sitename"`
+		WikiID    string `json:"wikiid"`
+		Base      string `json:"base"`
+		Generator string `json:"generator"`
+		Case      string `json:"case"`
+	}
+	type query struct {
+		General *general `json:"general"`
+	}
+	params := make(url.Values)
+	params.Set("meta", "siteinfo")
+	params.Set("siprop", "general")
+	var info SiteInfo
+	err := c.queryAll(ctx, params, func(raw json.RawMessage) error {
+		var response query
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return err
+		}
+		if response.General != nil {
+			info = SiteInfo{
+				Name:      response.General.SiteName,
+				DBName:    response.General.WikiID,
+				Base:      response.General.Base,
+				Generator: response.General.Generator,
+				Case:      response.General.Case,
+			}
+		}
+		return nil
+	})
+	return info, err
+}
@@ -0,0 +1,34 @@
+package mediawiki
+
+import (
+	"testing"
+
+	"github.com/stevearm/mediawiki-export/httpmock"
+)
+
+func TestGetSiteInfo(t *testing.T) {
+	client, server := setup()
+	defer server.Close()
+	setupLoginResponses(server)
+	server.QueueResponse(httpmock.Response{
+		ResponseCode: 200,
+		ContentType:  "application/json",
+		Content: `{"batchcomplete":"","query":{"general":{"mainpage":"Main Page","base":"http://wiki.example.org/wiki/Main_Page",
+			"sitename":"Example Wiki","generator":"MediaWiki 1.35.0","case":"first-letter","wikiid":"examplewiki"}}}`,
+	})
+	info, err := client.GetSiteInfo()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := SiteInfo{Name: "Example Wiki", DBName: "examplewiki", Base: "http://wiki.example.org/wiki/Main_Page", Generator: "MediaWiki 1.35.0", Case: "first-letter"}
+	if info != expected {
+		t.Errorf("Wrong site info: %+v", info)
+	}
+
+	requests := server.Requests()
+	checkLoginCalls(t, requests)
+	request := <-requests
+	if request.Url != "http://wiki.example.org/api.php?action=query&continue=&format=json&meta=siteinfo&siprop=general" {
+		t.Errorf("Bad call: %v", request)
+	}
+}
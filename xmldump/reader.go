@@ -0,0 +1,105 @@
+package xmldump
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+// Reads a dump one page at a time, so dumps of any size can be read
+type Reader struct {
+	decoder *xml.Decoder
+	info    SiteInfo
+	next    *Page // Read while looking for the site information, in dumps that have none
+}
+
+// Start reading a dump, up to and including its site information
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{decoder: xml.NewDecoder(r)}
+	root, err := reader.nextElement()
+	if err == io.EOF || (err == nil && root.Name.Local != "mediawiki") {
+		return nil, errors.New("Not a MediaWiki XML dump")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for {
+		element, err := reader.nextElement()
+		if err == io.EOF {
+			return reader, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch element.Name.Local {
+		case "siteinfo":
+			var info xmlSiteInfo
+			if err = reader.decoder.DecodeElement(&info, &element); err != nil {
+				return nil, err
+			}
+			reader.info = info.siteInfo()
+			return reader, nil
+		case "page":
+			page, err := reader.decodePage(element)
+			if err != nil {
+				return nil, err
+			}
+			reader.next = &page
+			return reader, nil
+		}
+		if err = reader.decoder.Skip(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// The site information at the start of the dump. Empty if the dump has none
+func (r *Reader) SiteInfo() SiteInfo {
+	return r.info
+}
+
+// Read the next page, with every revision the dump holds of it. Returns io.EOF after the last page
+func (r *Reader) Next() (Page, error) {
+	if r.next != nil {
+		page := *r.next
+		r.next = nil
+		return page, nil
+	}
+	for {
+		element, err := r.nextElement()
+		if err != nil {
+			return Page{}, err
+		}
+		if element.Name.Local == "page" {
+			return r.decodePage(element)
+		}
+		// Such as the logitem elements of log dumps
+		if err = r.decoder.Skip(); err != nil {
+			return Page{}, err
+		}
+	}
+}
+
+func (r *Reader) decodePage(element xml.StartElement) (Page, error) {
+	var page xmlPage
+	if err := r.decoder.DecodeElement(&page, &element); err != nil {
+		return Page{}, err
+	}
+	return page.page()
+}
+
+// Find the next opening tag at the current level, returning io.EOF once the enclosing element ends
+func (r *Reader) nextElement() (xml.StartElement, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			return token, nil
+		case xml.EndElement:
+			return xml.StartElement{}, io.EOF
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package xmldump
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, r io.Reader) (SiteInfo, []Page) {
+	reader, err := NewReader(r)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var pages []Page
+	for {
+		page, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		pages = append(pages, page)
+	}
+	return reader.SiteInfo(), pages
+}
+
+func TestReadWrittenDump(t *testing.T) {
+	info, pages := readAll(t, strings.NewReader(testDump))
+	if !reflect.DeepEqual(info, testSiteInfo) {
+		t.Errorf("Wrong site info: %+v", info)
+	}
+	// Dumps hold timestamps in UTC, and the format of every revision
+	expected := make([]Page, len(testPages))
+	for i, page := range testPages {
+		expected[i] = page
+		expected[i].Revisions = nil
+		for _, revision := range page.Revisions {
+			revision.Timestamp = revision.Timestamp.UTC()
+			revision.Format = DefaultFormat(revision.Model)
+			expected[i].Revisions = append(expected[i].Revisions, revision)
+		}
+	}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Errorf("Wrong pages:\n%+v", pages)
+	}
+}
+
+func TestReadDumpFixture(t *testing.T) {
+	file, err := os.Open("testdata/pages-articles.xml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer file.Close()
+	info, pages := readAll(t, file)
+	if info.SiteName != "Example Wiki" || info.Generator != "MediaWiki 1.39.3" || len(info.Namespaces) != 7 {
+		t.Errorf("Wrong site info: %+v", info)
+	}
+	if info.Namespaces[2] != (Namespace{Key: 0, Case: "first-letter"}) || info.Namespaces[6] != (Namespace{Key: 100, Case: "first-letter", Name: "Recipe"}) {
+		t.Errorf("Wrong namespaces: %+v", info.Namespaces)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("Wrong pages: %+v", pages)
+	}
+	expected := []Revision{
+		{
+			ID:          1,
+			Timestamp:   time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC),
+			Contributor: Contributor{IP: "192.0.2.1"},
+			Model:       "wikitext",
+			Format:      "text/x-wiki",
+			Text:        "Welcome!",
+			SHA1:        "e52e5e6cd50ef4de30d8a4fafbbfab41180cc200",
+		},
+		{
+			ID:          4,
+			ParentID:    1,
+			Timestamp:   time.Date(2020, 1, 3, 12, 30, 0, 0, time.UTC),
+			Contributor: Contributor{Username: "Alice", ID: 2},
+			Minor:       true,
+			Comment:     "Link the cake",
+			Model:       "wikitext",
+			Format:      "text/x-wiki",
+			Text:        "Welcome to the wiki.\n\nSee [[Recipe:Cake]].",
+			SHA1:        "9890010c66b0c3981cb534290784cf60c1a5856a",
+		},
+	}
+	if pages[0].Title != "Main Page" || pages[0].ID != 1 || !reflect.DeepEqual(pages[0].Revisions, expected) {
+		t.Errorf("Wrong page: %+v", pages[0])
+	}
+	if pages[1].Redirect != "Main Page" || pages[1].Revisions[0].Contributor != (Contributor{}) {
+		t.Errorf("Wrong redirect: %+v", pages[1])
+	}
+	cake := pages[2]
+	if cake.Title != "Recipe:Cake" || cake.Namespace != 100 || cake.Revisions[0].Text != "Mix flour & eggs, bake at <b>180</b>." || cake.Revisions[0].Comment != "Flour & eggs" {
+		t.Errorf("Wrong page: %+v", cake)
+	}
+}
+
+func TestReadBrokenDumps(t *testing.T) {
+	for _, dump := range []string{
+		"",
+		"<html><body>Not found</body></html>",
+		`<mediawiki><siteinfo><sitename>Truncated`,
+		`<mediawiki><page><title>A</title><revision><timestamp>yesterday</timestamp></revision></page></mediawiki>`,
+	} {
+		reader, err := NewReader(strings.NewReader(dump))
+		if err == nil {
+			_, err = reader.Next()
+		}
+		if err == nil || err == io.EOF {
+			t.Errorf("Should fail to read %q: %v", dump, err)
+		}
+	}
+}
+
+func TestSHA1FromBase36(t *testing.T) {
+	if actual := sha1FromBase36("phoiac9h4m842xq45sp7s6u21eteeq1"); actual != "da39a3ee5e6b4b0d3255bfef95601890afd80709" {
+		t.Errorf("Wrong sha1: %s", actual)
+	}
+	if actual := sha1FromBase36(""); actual != "" {
+		t.Errorf("Wrong sha1 of nothing: %s", actual)
+	}
+}
@@ -0,0 +1,51 @@
+package xmldump
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Writes a dump one page at a time, so it never has to be held in memory
+type Writer struct {
+	w       io.Writer
+	encoder *xml.Encoder
+	closed  bool
+}
+
+// Start a dump of the given wiki, writing the opening tag and site information
+func NewWriter(w io.Writer, info SiteInfo) (*Writer, error) {
+	header := fmt.Sprintf(`<mediawiki xmlns="%s" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" `+
+		`xsi:schemaLocation="%s %s" version="%s" xml:lang="en">`+"\n", XMLNamespace, XMLNamespace, schemaLocation, Version)
+	if _, err := io.WriteString(w, header); err != nil {
+		return nil, err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("  ", "  ")
+	if err := encoder.Encode(newXMLSiteInfo(info)); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, encoder: encoder}, nil
+}
+
+// Add a page, with the revisions it holds
+func (w *Writer) WritePage(page Page) error {
+	if w.closed {
+		return errors.New("Dump is already closed")
+	}
+	return w.encoder.Encode(newXMLPage(page))
+}
+
+// Finish the dump with the closing tag. This doesn't close the underlying writer
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.encoder.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, "\n</mediawiki>\n")
+	return err
+}
@@ -0,0 +1,148 @@
+package xmldump
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var testSiteInfo = SiteInfo{
+	SiteName:  "Example Wiki",
+	DBName:    "examplewiki",
+	Base:      "http://wiki.example.org/wiki/Main_Page",
+	Generator: "MediaWiki 1.35.0",
+	Case:      "first-letter",
+	Namespaces: []Namespace{
+		{Key: 0, Case: "first-letter"},
+		{Key: 10, Case: "first-letter", Name: "Template"},
+	},
+}
+
+var testPages = []Page{
+	{
+		Title:     "Home",
+		Namespace: 0,
+		ID:        1,
+		Revisions: []Revision{
+			{
+				ID:          10,
+				Timestamp:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				Contributor: Contributor{Username: "Alice"},
+				Comment:     "Created page",
+				Model:       "wikitext",
+				Text:        "Fish & <chips>",
+				SHA1:        "f7ff9e8b7bb2e09b70935a5d785e0cc5d9d0abf0",
+			},
+			{
+				ID:          11,
+				ParentID:    10,
+				Timestamp:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600)),
+				Contributor: Contributor{IP: "192.0.2.1"},
+				Minor:       true,
+				Model:       "wikitext",
+				Text:        "Fish",
+			},
+		},
+	},
+	{
+		Title:     "Template:Old",
+		Namespace: 10,
+		ID:        2,
+		Redirect:  "Template:New",
+		Revisions: []Revision{
+			{ID: 12, Timestamp: time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC), Model: "wikitext", Text: "#REDIRECT [[Template:New]]"},
+		},
+	},
+}
+
+const testDump = `<mediawiki xmlns="http://www.mediawiki.org/xml/export-0.10/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.mediawiki.org/xml/export-0.10/ http://www.mediawiki.org/xml/export-0.10.xsd" version="0.10" xml:lang="en">
+  <siteinfo>
+    <sitename>Example Wiki</sitename>
+    <dbname>examplewiki</dbname>
+    <base>http://wiki.example.org/wiki/Main_Page</base>
+    <generator>MediaWiki 1.35.0</generator>
+    <case>first-letter</case>
+    <namespaces>
+      <namespace key="0" case="first-letter"></namespace>
+      <namespace key="10" case="first-letter">Template</namespace>
+    </namespaces>
+  </siteinfo>
+  <page>
+    <title>Home</title>
+    <ns>0</ns>
+    <id>1</id>
+    <revision>
+      <id>10</id>
+      <timestamp>2020-01-01T00:00:00Z</timestamp>
+      <contributor>
+        <username>Alice</username>
+      </contributor>
+      <comment>Created page</comment>
+      <model>wikitext</model>
+      <format>text/x-wiki</format>
+      <text xml:space="preserve" bytes="14">Fish &amp; &lt;chips&gt;</text>
+      <sha1>syvtbocopvw4f81bf07ocly0sl8ybqo</sha1>
+    </revision>
+    <revision>
+      <id>11</id>
+      <parentid>10</parentid>
+      <timestamp>2020-01-02T02:04:05Z</timestamp>
+      <contributor>
+        <ip>192.0.2.1</ip>
+      </contributor>
+      <minor></minor>
+      <model>wikitext</model>
+      <format>text/x-wiki</format>
+      <text xml:space="preserve" bytes="4">Fish</text>
+      <sha1></sha1>
+    </revision>
+  </page>
+  <page>
+    <title>Template:Old</title>
+    <ns>10</ns>
+    <id>2</id>
+    <redirect title="Template:New"></redirect>
+    <revision>
+      <id>12</id>
+      <timestamp>2020-01-03T00:00:00Z</timestamp>
+      <contributor deleted="deleted"></contributor>
+      <model>wikitext</model>
+      <format>text/x-wiki</format>
+      <text xml:space="preserve" bytes="26">#REDIRECT [[Template:New]]</text>
+      <sha1></sha1>
+    </revision>
+  </page>
+</mediawiki>
+`
+
+func TestWriter(t *testing.T) {
+	var output bytes.Buffer
+	writer, err := NewWriter(&output, testSiteInfo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, page := range testPages {
+		if err = writer.WritePage(page); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output.String() != testDump {
+		t.Errorf("Wrong dump:\n%s", output.String())
+	}
+	if err = writer.WritePage(testPages[0]); err == nil {
+		t.Errorf("Should not write to a closed dump")
+	}
+}
+
+func TestSHA1ToBase36(t *testing.T) {
+	// The SHA-1 of an empty revision, as it appears in Wikimedia dumps
+	if sha1 := sha1ToBase36("da39a3ee5e6b4b0d3255bfef95601890afd80709"); sha1 != "phoiac9h4m842xq45sp7s6u21eteeq1" {
+		t.Errorf("Wrong SHA-1: %s", sha1)
+	}
+	if sha1 := sha1ToBase36("0000000000000000000000000000000000000001"); sha1 != "0000000000000000000000000000001" {
+		t.Errorf("Wrong SHA-1: %s", sha1)
+	}
+}
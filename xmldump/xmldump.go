@@ -0,0 +1,269 @@
+// Package xmldump reads and writes the XML export format of MediaWiki, as produced by Special:Export and
+// dumpBackup.php, and read back by importDump.php
+package xmldump
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// The version of the export format that is written. Every MediaWiki since 1.21 can import it. Any version is read
+const (
+	Version        = "0.10"
+	XMLNamespace   = "http://www.mediawiki.org/xml/export-0.10/"
+	schemaLocation = "http://www.mediawiki.org/xml/export-0.10.xsd"
+)
+
+// Timestamps are always in UTC, to the second
+const timestampFormat = "2006-01-02T15:04:05Z"
+
+// General information about the wiki a dump came from
+type SiteInfo struct {
+	SiteName   string
+	DBName     string
+	Base       string // The url of the main page
+	Generator  string // Such as "MediaWiki 1.35.0"
+	Case       string // Either first-letter or case-sensitive
+	Namespaces []Namespace
+}
+
+type Namespace struct {
+	Key  int
+	Case string
+	Name string // Empty for the main namespace
+}
+
+// A page along with the revisions being dumped, oldest first
+type Page struct {
+	Title     string
+	Namespace int
+	ID        int
+	Redirect  string // The title the page redirects to, if it is a redirect
+	Revisions []Revision
+}
+
+type Revision struct {
+	ID          int
+	ParentID    int // Zero for the revision that created the page
+	Timestamp   time.Time
+	Contributor Contributor
+	Minor       bool
+	Comment     string
+	Model       string // Such as wikitext
+	Format      string // Such as text/x-wiki
+	Text        string
+	SHA1        string // In hex, as the api returns it. Dumps hold it in base 36
+}
+
+// Who saved a revision: a user, an IP address for anonymous edits, or neither if it was hidden
+type Contributor struct {
+	Username string
+	ID       int
+	IP       string
+}
+
+// The content format MediaWiki uses by default for each of its built in content models
+var defaultFormats = map[string]string{
+	"wikitext":   "text/x-wiki",
+	"javascript": "text/javascript",
+	"css":        "text/css",
+	"json":       "application/json",
+	"text":       "text/plain",
+}
+
+// The default format of a content model, falling back to wikitext's for models added by extensions
+func DefaultFormat(model string) string {
+	if format, found := defaultFormats[model]; found {
+		return format
+	}
+	return defaultFormats["wikitext"]
+}
+
+// Dumps hold SHA-1s as 31 digit, zero padded base 36 numbers. Returns "" if the input isn't hex
+func sha1ToBase36(hex string) string {
+	var n big.Int
+	if _, ok := n.SetString(hex, 16); !ok {
+		return ""
+	}
+	return leftPad(n.Text(36), 31)
+}
+
+// The hex SHA-1 of a base 36 one from a dump, as the api would return it. Returns "" if the input isn't base 36
+func sha1FromBase36(base36 string) string {
+	var n big.Int
+	if _, ok := n.SetString(base36, 36); !ok {
+		return ""
+	}
+	return leftPad(n.Text(16), 40)
+}
+
+func leftPad(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return strings.Repeat("0", width-len(s)) + s
+}
+
+// The XML structure of the format. Optional elements are pointers or omitempty so they are left out when unset
+
+type xmlSiteInfo struct {
+	XMLName    xml.Name       `xml:"siteinfo"`
+	SiteName   string         `xml:"sitename"`
+	DBName     string         `xml:"dbname"`
+	Base       string         `xml:"base"`
+	Generator  string         `xml:"generator"`
+	Case       string         `xml:"case"`
+	Namespaces []xmlNamespace `xml:"namespaces>namespace"`
+}
+
+type xmlNamespace struct {
+	Key  int    `xml:"key,attr"`
+	Case string `xml:"case,attr"`
+	Name string `xml:",chardata"`
+}
+
+type xmlPage struct {
+	XMLName   xml.Name      `xml:"page"`
+	Title     string        `xml:"title"`
+	Namespace int           `xml:"ns"`
+	ID        int           `xml:"id"`
+	Redirect  *xmlRedirect  `xml:"redirect"`
+	Revisions []xmlRevision `xml:"revision"`
+}
+
+type xmlRedirect struct {
+	Title string `xml:"title,attr"`
+}
+
+type xmlRevision struct {
+	ID          int            `xml:"id"`
+	ParentID    int            `xml:"parentid,omitempty"`
+	Timestamp   string         `xml:"timestamp"`
+	Contributor xmlContributor `xml:"contributor"`
+	Minor       *struct{}      `xml:"minor"`
+	Comment     *string        `xml:"comment"`
+	Model       string         `xml:"model"`
+	Format      string         `xml:"format"`
+	Text        xmlText        `xml:"text"`
+	SHA1        string         `xml:"sha1"`
+}
+
+type xmlContributor struct {
+	Deleted  string `xml:"deleted,attr,omitempty"`
+	Username string `xml:"username,omitempty"`
+	ID       int    `xml:"id,omitempty"`
+	IP       string `xml:"ip,omitempty"`
+}
+
+type xmlText struct {
+	Space string `xml:"http://www.w3.org/XML/1998/namespace space,attr"`
+	Bytes int    `xml:"bytes,attr"`
+	Text  string `xml:",chardata"`
+}
+
+func newXMLSiteInfo(info SiteInfo) xmlSiteInfo {
+	result := xmlSiteInfo{
+		SiteName:  info.SiteName,
+		DBName:    info.DBName,
+		Base:      info.Base,
+		Generator: info.Generator,
+		Case:      info.Case,
+	}
+	for _, namespace := range info.Namespaces {
+		result.Namespaces = append(result.Namespaces, xmlNamespace(namespace))
+	}
+	return result
+}
+
+func newXMLPage(page Page) xmlPage {
+	result := xmlPage{Title: page.Title, Namespace: page.Namespace, ID: page.ID}
+	if page.Redirect != "" {
+		result.Redirect = &xmlRedirect{Title: page.Redirect}
+	}
+	for _, revision := range page.Revisions {
+		format := revision.Format
+		if format == "" {
+			format = DefaultFormat(revision.Model)
+		}
+		model := revision.Model
+		if model == "" {
+			model = "wikitext"
+		}
+		xmlRev := xmlRevision{
+			ID:        revision.ID,
+			ParentID:  revision.ParentID,
+			Timestamp: revision.Timestamp.UTC().Format(timestampFormat),
+			Contributor: xmlContributor{
+				Username: revision.Contributor.Username,
+				ID:       revision.Contributor.ID,
+				IP:       revision.Contributor.IP,
+			},
+			Model:  model,
+			Format: format,
+			Text:   xmlText{Space: "preserve", Bytes: len(revision.Text), Text: revision.Text},
+			SHA1:   sha1ToBase36(revision.SHA1),
+		}
+		if xmlRev.Contributor == (xmlContributor{}) {
+			xmlRev.Contributor.Deleted = "deleted"
+		}
+		if revision.Minor {
+			xmlRev.Minor = &struct{}{}
+		}
+		if revision.Comment != "" {
+			comment := revision.Comment
+			xmlRev.Comment = &comment
+		}
+		result.Revisions = append(result.Revisions, xmlRev)
+	}
+	return result
+}
+
+func (info xmlSiteInfo) siteInfo() SiteInfo {
+	result := SiteInfo{
+		SiteName:  info.SiteName,
+		DBName:    info.DBName,
+		Base:      info.Base,
+		Generator: info.Generator,
+		Case:      info.Case,
+	}
+	for _, namespace := range info.Namespaces {
+		result.Namespaces = append(result.Namespaces, Namespace(namespace))
+	}
+	return result
+}
+
+func (page xmlPage) page() (Page, error) {
+	result := Page{Title: page.Title, Namespace: page.Namespace, ID: page.ID}
+	if page.Redirect != nil {
+		result.Redirect = page.Redirect.Title
+	}
+	for _, xmlRev := range page.Revisions {
+		timestamp, err := time.Parse(timestampFormat, xmlRev.Timestamp)
+		if err != nil {
+			return result, fmt.Errorf("Revision %d of %s has a bad timestamp: %s", xmlRev.ID, page.Title, xmlRev.Timestamp)
+		}
+		revision := Revision{
+			ID:        xmlRev.ID,
+			ParentID:  xmlRev.ParentID,
+			Timestamp: timestamp,
+			Contributor: Contributor{
+				Username: xmlRev.Contributor.Username,
+				ID:       xmlRev.Contributor.ID,
+				IP:       xmlRev.Contributor.IP,
+			},
+			Minor:  xmlRev.Minor != nil,
+			Model:  xmlRev.Model,
+			Format: xmlRev.Format,
+			Text:   xmlRev.Text.Text,
+			SHA1:   sha1FromBase36(xmlRev.SHA1),
+		}
+		if xmlRev.Comment != nil {
+			revision.Comment = *xmlRev.Comment
+		}
+		result.Revisions = append(result.Revisions, revision)
+	}
+	return result, nil
+}